@@ -0,0 +1,4 @@
+// Package gophermartpb contains the protobuf messages and gRPC services of the Gophermart API
+package gophermartpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative gophermart.proto
@@ -3,70 +3,377 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"gophermart/domain/repository"
 	"gophermart/domain/service"
 	"gophermart/internal/app"
 	"gophermart/internal/config"
+	"gophermart/internal/grpc"
+	"gophermart/internal/health"
 	"gophermart/internal/http"
+	"gophermart/internal/logging"
+	"gophermart/internal/metrics"
+	"gophermart/internal/migrate"
+	"gophermart/internal/notify"
+	"gophermart/internal/oidc"
+	"gophermart/internal/pgxstore"
 	"gophermart/internal/postgres"
+	"gophermart/internal/publish"
+	"gophermart/internal/sqlite"
+	"gophermart/internal/tracing"
 	"log"
+	"log/slog"
 	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 func main() {
+	// The first argument may select a command, the default one runs the server
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	migrateAction, migrateSteps := "", 1
+
+	switch command {
+	case "serve", "seed-admin":
+	case "migrate":
+		// migrate takes an action and, for down, the number of migrations to revert
+		migrateAction = "up"
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			migrateAction, args = args[0], args[1:]
+		}
+		if !slices.Contains([]string{"up", "down", "status"}, migrateAction) {
+			log.Fatalf("Unknown migrate action %q, expected up, down or status", migrateAction)
+		}
+		if migrateAction == "down" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			steps, err := strconv.Atoi(args[0])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of migrations %q", args[0])
+			}
+			migrateSteps, args = steps, args[1:]
+		}
+	default:
+		log.Fatalf("Unknown command %q", command)
+	}
+
 	// Load configuration
-	cfg := config.NewConfig()
+	cfg, err := config.NewConfig(args)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Create logger, its level can be changed at runtime through the admin API
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, logLevel)
+	if err != nil {
+		log.Fatalf("Invalid log format: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// Set up tracing before anything that records spans is created
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter, "gophermart")
+	if err != nil {
+		fatal(logger, "Failed to set up tracing", "error", err)
+	}
 
-	// Parse database URI
+	// Parse database URI, its scheme selects the storage backend
 	dbURL, err := url.Parse(cfg.DatabaseURI)
 	if err != nil {
-		log.Fatalf("Invalid database URI: %v", err)
-	}
-
-	// Extract database parameters
-	dbConfig := postgres.DBConfig{
-		Host: dbURL.Hostname(),
-		Port: dbURL.Port(),
-		User: dbURL.User.Username(),
-		Password: func() string {
-			pass, _ := dbURL.User.Password()
-			return pass
-		}(),
-		DBName: dbURL.Path[1:],
-		SSLMode: func() string {
-			q := dbURL.Query()
-			return q.Get("sslmode")
-		}(),
-	}
-
-	// Initialize database
-	db, err := postgres.NewDB(dbConfig)
+		fatal(logger, "Invalid database URI", "error", err)
+	}
+
+	// Initialize database, backend is the package whose repositories are used
+	var db *sql.DB
+	var pool *pgxpool.Pool
+	backend := dbURL.Scheme
+	if backend == "postgres" || backend == "postgresql" {
+		backend = cfg.PostgresDriver
+		if backend != "pgx" && backend != "pq" {
+			fatal(logger, "Unknown Postgres driver, expected pgx or pq", "driver", cfg.PostgresDriver)
+		}
+	}
+
+	// Pool settings of both Postgres drivers, the URI itself is passed to them unchanged
+	poolConfig := postgres.PoolConfig{
+		MaxOpenConns:     cfg.DBMaxOpenConns,
+		MaxIdleConns:     cfg.DBMaxIdleConns,
+		ConnMaxLifetime:  cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime:  cfg.DBConnMaxIdleTime,
+		StatementTimeout: cfg.DBStatementTimeout,
+		ConnectTimeout:   cfg.DBConnectTimeout,
+	}
+
+	switch backend {
+	case "pgx":
+		pool, err = pgxstore.NewPool(context.Background(), cfg.DatabaseURI, poolConfig, logger)
+		if err == nil {
+			// Migrations and health checks use database/sql on top of the same pool
+			db = stdlib.OpenDBFromPool(pool)
+			defer pool.Close()
+		}
+	case "pq":
+		db, err = postgres.NewDB(context.Background(), cfg.DatabaseURI, poolConfig, logger)
+	case "sqlite":
+		// sqlite:///var/lib/gophermart.db is an absolute path, sqlite://gophermart.db a relative one,
+		// the query holds extra driver parameters
+		db, err = sqlite.NewDB(dbURL.Host+dbURL.Path, dbURL.Query(), logger)
+	default:
+		fatal(logger, "Unsupported database URI scheme, expected postgres or sqlite", "scheme", dbURL.Scheme)
+	}
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal(logger, "Failed to initialize database", "error", err)
 	}
 	defer db.Close()
 
+	// Manage the schema, replicas starting together apply migrations one at a time
+	var migrator *migrate.Migrator
+	if backend == "sqlite" {
+		migrator, err = sqlite.NewMigrator(db, logger)
+	} else {
+		migrator, err = postgres.NewMigrator(db, logger)
+	}
+	if err != nil {
+		fatal(logger, "Failed to load migrations", "error", err)
+	}
+
+	if command == "migrate" {
+		if err := runMigrate(migrator, migrateAction, migrateSteps); err != nil {
+			fatal(logger, "Migration failed", "error", err)
+		}
+		return
+	}
+
+	if cfg.MigrateOnStart {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			fatal(logger, "Failed to apply migrations", "error", err)
+		}
+	}
+
+	// Create metrics, including the connection pool statistics
+	appMetrics := metrics.New()
 	// Create repositories
-	userRepo := postgres.NewUserRepo(db)
-	orderRepo := postgres.NewOrderRepo(db)
-	balanceRepo := postgres.NewBalanceRepo(db)
-	withdrawalRepo := postgres.NewWithdrawalRepo(db)
+	var (
+		userRepo             repository.UserRepository
+		orderRepo            repository.OrderRepository
+		balanceRepo          repository.BalanceRepository
+		withdrawalRepo       repository.WithdrawalRepository
+		passwordResetRepo    repository.PasswordResetRepository
+		recoveryCodeRepo     repository.RecoveryCodeRepository
+		externalIdentityRepo repository.ExternalIdentityRepository
+		idempotencyRepo      repository.IdempotencyRepository
+		webhookRepo          repository.WebhookRepository
+		outboxRepo           repository.OutboxRepository
+		transactor           repository.Transactor
+	)
+	switch backend {
+	case "sqlite":
+		appMetrics.RegisterDB(db, "sqlite")
+
+		userRepo = sqlite.NewUserRepo(db)
+		orderRepo = sqlite.NewOrderRepo(db)
+		balanceRepo = sqlite.NewBalanceRepo(db)
+		withdrawalRepo = sqlite.NewWithdrawalRepo(db)
+		passwordResetRepo = sqlite.NewPasswordResetRepo(db)
+		recoveryCodeRepo = sqlite.NewRecoveryCodeRepo(db)
+		externalIdentityRepo = sqlite.NewExternalIdentityRepo(db)
+		idempotencyRepo = sqlite.NewIdempotencyRepo(db)
+		webhookRepo = sqlite.NewWebhookRepo(db)
+		outboxRepo = sqlite.NewOutboxRepo(db)
+		transactor = sqlite.NewTransactor(db)
+	case "pgx":
+		appMetrics.RegisterPool(pool, "postgres")
+
+		userRepo = pgxstore.NewUserRepo(pool)
+		orderRepo = pgxstore.NewOrderRepo(pool)
+		balanceRepo = pgxstore.NewBalanceRepo(pool)
+		withdrawalRepo = pgxstore.NewWithdrawalRepo(pool)
+		passwordResetRepo = pgxstore.NewPasswordResetRepo(pool)
+		recoveryCodeRepo = pgxstore.NewRecoveryCodeRepo(pool)
+		externalIdentityRepo = pgxstore.NewExternalIdentityRepo(pool)
+		idempotencyRepo = pgxstore.NewIdempotencyRepo(pool)
+		webhookRepo = pgxstore.NewWebhookRepo(pool)
+		outboxRepo = pgxstore.NewOutboxRepo(pool)
+		transactor = pgxstore.NewTransactor(pool)
+	default:
+		appMetrics.RegisterDB(db, "postgres")
+
+		userRepo = postgres.NewUserRepo(db)
+		orderRepo = postgres.NewOrderRepo(db)
+		balanceRepo = postgres.NewBalanceRepo(db)
+		withdrawalRepo = postgres.NewWithdrawalRepo(db)
+		passwordResetRepo = postgres.NewPasswordResetRepo(db)
+		recoveryCodeRepo = postgres.NewRecoveryCodeRepo(db)
+		externalIdentityRepo = postgres.NewExternalIdentityRepo(db)
+		idempotencyRepo = postgres.NewIdempotencyRepo(db)
+		webhookRepo = postgres.NewWebhookRepo(db)
+		outboxRepo = postgres.NewOutboxRepo(db)
+		transactor = postgres.NewTransactor(db)
+	}
+
+	// Create notifier
+	var notifier service.Notifier
+	switch cfg.Notifier {
+	case "file":
+		notifier = notify.NewFileNotifier(cfg.NotifierFile)
+	default:
+		notifier = notify.NewLogNotifier(logger)
+	}
+
+	// Create domain event publisher
+	var publisher service.EventPublisher
+	switch cfg.EventPublisher {
+	case "http":
+		if cfg.EventPublisherURL == "" {
+			fatal(logger, "Event publisher URL is required for the http publisher")
+		}
+		publisher = publish.NewHTTPPublisher(cfg.EventPublisherURL)
+	default:
+		publisher = publish.NewLogPublisher(logger)
+	}
 
 	// Create services
-	userService := service.NewUserService(userRepo)
-	orderService := service.NewOrderService(orderRepo, balanceRepo)
-	balanceService := service.NewBalanceService(balanceRepo, withdrawalRepo, orderRepo)
-	accrualService := service.NewAccrualService(orderRepo, cfg.AccrualSystemAddress, 1*time.Minute)
+	passwordPolicy := service.PasswordPolicy{
+		MinLength:      cfg.PasswordMinLength,
+		RequireUpper:   cfg.PasswordRequireUpper,
+		RequireLower:   cfg.PasswordRequireLower,
+		RequireDigit:   cfg.PasswordRequireDigit,
+		RequireSpecial: cfg.PasswordRequireSpecial,
+	}
+	userService := service.NewUserService(
+		userRepo,
+		passwordResetRepo,
+		recoveryCodeRepo,
+		notifier,
+		passwordPolicy,
+		cfg.PasswordResetTTL,
+		logger,
+	)
+	identityService := service.NewIdentityService(userRepo, externalIdentityRepo, logger)
+	events := service.NewEventBus(service.DefaultEventHistory)
+	orderService := service.NewOrderService(orderRepo, balanceRepo, outboxRepo, transactor, events, appMetrics, logger)
+	balanceService := service.NewBalanceService(
+		balanceRepo,
+		withdrawalRepo,
+		orderRepo,
+		outboxRepo,
+		transactor,
+		events,
+		appMetrics,
+		logger,
+	)
+	accrualService := service.NewAccrualService(
+		orderRepo,
+		orderService,
+		cfg.AccrualSystemAddress,
+		1*time.Minute,
+		appMetrics,
+		logger,
+	)
+	outboxRelay := service.NewOutboxRelay(outboxRepo, publisher, 1*time.Second, logger)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, cfg.IdempotencyTTL, 1*time.Hour, logger)
+	webhookService := service.NewWebhookService(
+		webhookRepo,
+		outboxRepo,
+		transactor,
+		cfg.WebhookMaxAttempts,
+		cfg.WebhookBackoff,
+		5*time.Second,
+		cfg.WebhookAllowPrivate,
+		logger,
+	)
+	adminService := service.NewAdminService(
+		userRepo,
+		orderRepo,
+		balanceRepo,
+		withdrawalRepo,
+		outboxRepo,
+		transactor,
+		orderService,
+		accrualService,
+		events,
+		logger,
+	)
+
+	// Seed the initial admin and exit
+	if command == "seed-admin" {
+		if err := seedAdmin(userService, cfg); err != nil {
+			fatal(logger, "Failed to seed admin", "error", err)
+		}
+		return
+	}
+
+	// Discover the OpenID Connect provider if configured
+	var oidcProvider *oidc.Provider
+	if cfg.OIDCIssuer != "" {
+		oidcProvider, err = oidc.NewProvider(context.Background(), oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+		})
+		if err != nil {
+			fatal(logger, "Failed to initialize OIDC provider", "error", err)
+		}
+	}
+
+	// Readiness checks, the accrual system is reported but its outage does not take the service out of rotation
+	checker := health.NewChecker()
+	checker.Add("database", db.PingContext)
+	checker.Add("migrations", migrator.CheckApplied)
+	checker.AddOptional("accrual", accrualService.CheckReachable)
 
 	// Create HTTP server
-	server := http.NewServer(cfg.ServerAddress, userService, orderService, balanceService)
+	server := http.NewServer(
+		cfg.ServerAddress,
+		userService,
+		orderService,
+		balanceService,
+		identityService,
+		adminService,
+		idempotencyService,
+		webhookService,
+		events,
+		oidcProvider,
+		checker,
+		appMetrics,
+		logger,
+		logLevel,
+	)
+
+	// Create gRPC server
+	grpcServer := grpc.NewServer(cfg.GRPCAddress, userService, orderService, balanceService, logger)
 
 	// Create application
-	app := app.NewApp(server, accrualService)
+	app := app.NewApp(
+		server,
+		grpcServer,
+		accrualService,
+		idempotencyService,
+		webhookService,
+		outboxRelay,
+		checker,
+		cfg.ShutdownDelay,
+		cfg.ShutdownTimeout,
+		logger,
+	)
 
 	// Handle graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -79,8 +386,71 @@ func main() {
 	}()
 
 	// Start the application
-	if err := app.Start(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+	err = app.Start(ctx)
+
+	// Flush the spans of the last requests, fatal exits without running deferred calls
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer flushCancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
+	}
+
+	if err != nil {
+		fatal(logger, "Application stopped", "error", err)
+	}
+}
+
+// fatal logs an error that stops the service and exits
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// seedAdmin creates the initial admin or promotes an existing user
+func seedAdmin(userService *service.UserService, cfg *config.Config) error {
+	if cfg.AdminLogin == "" {
+		return errors.New("admin login is required")
+	}
+
+	user, err := userService.SeedAdmin(context.Background(), cfg.AdminLogin, cfg.AdminPassword)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User %q (id %d) is an admin\n", user.Login, user.ID)
+	return nil
+}
+
+// runMigrate applies, reverts or lists the schema migrations
+func runMigrate(migrator *migrate.Migrator, action string, steps int) error {
+	ctx := context.Background()
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migrations\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
 	}
+
+	return nil
 }
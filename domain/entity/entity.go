@@ -1,15 +1,82 @@
 package entity
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // User represents a user in the system
 type User struct {
+	ID           int64     `json:"id"`
+	Login        string    `json:"login"`
+	Password     string    `json:"-"` // Password hash, not exposed in JSON
+	Role         string    `json:"role"`
+	TokenVersion int       `json:"-"` // Incremented to revoke previously issued tokens
+	TOTPSecret   string    `json:"-"` // Base32 TOTP secret, set on enrollment
+	TOTPEnabled  bool      `json:"totp_enabled"`
+	Blocked      bool      `json:"blocked"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ExternalIdentity links a user to an account at an external identity provider
+type ExternalIdentity struct {
 	ID        int64     `json:"id"`
-	Login     string    `json:"login"`
-	Password  string    `json:"-"` // Password hash, not exposed in JSON
+	UserID    int64     `json:"user_id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// IdempotencyRecord stores the response to a request made with an Idempotency-Key
+type IdempotencyRecord struct {
+	UserID      int64               `json:"user_id"`
+	Key         string              `json:"key"`
+	RequestHash string              `json:"request_hash"` // Fingerprint of method, path and body
+	Completed   bool                `json:"completed"`
+	StatusCode  int                 `json:"status_code"`
+	Headers     map[string][]string `json:"headers"`
+	Body        []byte              `json:"body"`
+	CreatedAt   time.Time           `json:"created_at"`
+	ExpiresAt   time.Time           `json:"expires_at"`
+}
+
+// WebhookSubscription registers a URL to be notified about a user's events
+type WebhookSubscription struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"events"`
+	Secret     string    `json:"-"` // HMAC key for payload signatures, not exposed in JSON
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is a queued notification of one event to one subscription
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	EventType      string     `json:"event"`
+	Payload        []byte     `json:"-"` // Signed JSON body, kept verbatim for retries
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// OutboxEvent is a domain event recorded together with the change that caused it
+type OutboxEvent struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	UserID      int64           `json:"user_id"`
+	Payload     json.RawMessage `json:"data"`
+	CreatedAt   time.Time       `json:"created_at"`
+	PublishedAt *time.Time      `json:"-"`
+	Attempts    int             `json:"-"` // Failed publish attempts
+	LastError   string          `json:"-"`
+}
+
 // Order represents an order in the system
 type Order struct {
 	ID         string    `json:"id"`
@@ -27,6 +94,16 @@ type Balance struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// BalanceAdjustment represents a manual balance change made from the back office
+type BalanceAdjustment struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	AdminID   int64     `json:"admin_id"`
+	Amount    float64   `json:"amount"` // Positive credits, negative debits the balance
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // Withdrawal represents a withdrawal operation
 type Withdrawal struct {
 	ID          int64     `json:"id"`
@@ -36,6 +113,23 @@ type Withdrawal struct {
 	ProcessedAt time.Time `json:"processed_at"`
 }
 
+// PasswordReset represents a one-time password reset token
+type PasswordReset struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	TokenHash string     `json:"-"` // SHA-256 of the token, the token itself is never stored
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// User roles
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
 // OrderStatus represents possible order statuses
 const (
 	StatusNew        = "NEW"
@@ -43,3 +137,10 @@ const (
 	StatusInvalid    = "INVALID"
 	StatusProcessed  = "PROCESSED"
 )
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
@@ -9,4 +9,6 @@ import (
 type BalanceRepository interface {
 	GetOrCreate(ctx context.Context, userID int64) (*entity.Balance, error)
 	UpdateBalance(ctx context.Context, userID int64, amount float64, isWithdrawal bool) error
+	Adjust(ctx context.Context, adjustment *entity.BalanceAdjustment) error
+	GetAdjustmentsByUserID(ctx context.Context, userID int64) ([]entity.BalanceAdjustment, error)
 }
@@ -0,0 +1,12 @@
+// Package repository defines the storage interfaces used by the domain services.
+// Implementations do not log; they return wrapped errors, which the services log with the request context.
+package repository
+
+import "errors"
+
+// Errors returned by repository implementations
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrAlreadyExists     = errors.New("already exists")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+	"gophermart/domain/entity"
+)
+
+// ExternalIdentityRepository defines methods to work with external identities
+type ExternalIdentityRepository interface {
+	Create(ctx context.Context, identity *entity.ExternalIdentity) error
+	GetByIssuerSubject(ctx context.Context, issuer, subject string) (*entity.ExternalIdentity, error)
+}
@@ -0,0 +1,30 @@
+package repository
+
+import "time"
+
+// Cursor identifies the last row of the previous page in keyset pagination
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+// OrderFilter describes a page of a user's orders
+type OrderFilter struct {
+	UserID    int64
+	Statuses  []string  // empty means any status
+	From      time.Time // inclusive, zero means unbounded
+	To        time.Time // exclusive, zero means unbounded
+	Ascending bool      // by upload time, newest first by default
+	After     *Cursor   // nil means the first page
+	Limit     int
+}
+
+// WithdrawalFilter describes a page of a user's withdrawals
+type WithdrawalFilter struct {
+	UserID    int64
+	From      time.Time // inclusive, zero means unbounded
+	To        time.Time // exclusive, zero means unbounded
+	Ascending bool      // by processing time, newest first by default
+	After     *Cursor   // nil means the first page
+	Limit     int
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"gophermart/domain/entity"
+)
+
+// IdempotencyRepository defines methods to work with idempotency records
+type IdempotencyRepository interface {
+	// Create inserts an in-progress record, it reports false if the key is already taken
+	Create(ctx context.Context, record *entity.IdempotencyRecord) (bool, error)
+	Get(ctx context.Context, userID int64, key string) (*entity.IdempotencyRecord, error)
+	Complete(ctx context.Context, record *entity.IdempotencyRecord) error
+	Delete(ctx context.Context, userID int64, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
@@ -5,11 +5,23 @@ import (
 	"gophermart/domain/entity"
 )
 
+// BatchInsertResult describes the outcome of inserting one order of a batch
+type BatchInsertResult struct {
+	ID       string
+	Inserted bool
+	OwnerID  int64 // owner of the already existing order when not inserted
+}
+
 // OrderRepository defines methods to work with orders
 type OrderRepository interface {
 	Create(ctx context.Context, order *entity.Order) error
+	CreateBatch(ctx context.Context, userID int64, ids []string) ([]BatchInsertResult, error)
 	GetByID(ctx context.Context, id string) (*entity.Order, error)
 	GetByUserID(ctx context.Context, userID int64) ([]entity.Order, error)
-	Update(ctx context.Context, order *entity.Order) error
+	List(ctx context.Context, filter OrderFilter) ([]entity.Order, error)
+	// GetByStatuses returns up to limit orders of any user with one of the statuses, oldest first
+	GetByStatuses(ctx context.Context, statuses []string, limit int) ([]entity.Order, error)
+	CountByStatuses(ctx context.Context, statuses []string) (int, error)
+	UpdateStatus(ctx context.Context, order *entity.Order, from string) (bool, error)
 	CheckExists(ctx context.Context, id string) (bool, int64, error)
 }
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"time"
+)
+
+// OutboxRepository defines methods to work with the transactional outbox
+type OutboxRepository interface {
+	// Add records events, within the transaction of ctx if there is one
+	Add(ctx context.Context, events ...entity.OutboxEvent) error
+	// ClaimUnpublished leases up to limit of the oldest unclaimed unpublished events by hiding them from other claims for lease
+	ClaimUnpublished(ctx context.Context, limit int, lease time.Duration) ([]entity.OutboxEvent, error)
+	// ReleaseClaims makes claimed events that were not published available to the next claim again
+	ReleaseClaims(ctx context.Context, ids []int64) error
+	MarkPublished(ctx context.Context, ids []int64) error
+	RecordFailure(ctx context.Context, id int64, reason string) error
+	// GetNotFannedOut retrieves the oldest events not yet queued for webhooks, locking them when called within a transaction
+	GetNotFannedOut(ctx context.Context, limit int) ([]entity.OutboxEvent, error)
+	MarkFannedOut(ctx context.Context, ids []int64) error
+	// DeletePublished deletes events published and queued for webhooks before the given time
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
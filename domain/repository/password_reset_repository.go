@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+	"gophermart/domain/entity"
+)
+
+// PasswordResetRepository defines methods to work with password reset tokens
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset *entity.PasswordReset) error
+	Consume(ctx context.Context, tokenHash string) (*entity.PasswordReset, error)
+}
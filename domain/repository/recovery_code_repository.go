@@ -0,0 +1,9 @@
+package repository
+
+import "context"
+
+// RecoveryCodeRepository defines methods to work with two-factor recovery codes
+type RecoveryCodeRepository interface {
+	Replace(ctx context.Context, userID int64, codeHashes []string) error
+	Consume(ctx context.Context, userID int64, codeHash string) error
+}
@@ -0,0 +1,105 @@
+package repotest
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"strconv"
+	"testing"
+)
+
+// Bench runs the benchmarks against the repositories created by newRepos, so that backends
+// can be compared on the same database. BenchmarkPgx in internal/pgxstore and BenchmarkPq in
+// internal/postgres call it with the repositories on the database of TEST_DATABASE_URI:
+//
+//	TEST_DATABASE_URI=postgres://... go test -run '^$' -bench . ./internal/pgxstore ./internal/postgres
+func Bench(b *testing.B, newRepos Factory) {
+	b.Run("GetUserByID", func(b *testing.B) { benchGetUserByID(b, newRepos) })
+	b.Run("CreateOrder", func(b *testing.B) { benchCreateOrder(b, newRepos) })
+	for _, size := range []int{10, 1000} {
+		b.Run("CreateBatch/"+strconv.Itoa(size), func(b *testing.B) { benchCreateBatch(b, newRepos, size) })
+	}
+	b.Run("ListOrders", func(b *testing.B) { benchListOrders(b, newRepos) })
+	b.Run("UpdateBalance", func(b *testing.B) { benchUpdateBalance(b, newRepos) })
+}
+
+// benchGetUserByID reads the same user repeatedly
+func benchGetUserByID(b *testing.B, newRepos Factory) {
+	ctx, repos := context.Background(), newRepos(b)
+	user := createUser(b, repos, "alice")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repos.Users.GetByID(ctx, user.ID); err != nil {
+			b.Fatalf("GetByID: %v", err)
+		}
+	}
+}
+
+// benchCreateOrder uploads one order per iteration
+func benchCreateOrder(b *testing.B, newRepos Factory) {
+	ctx, repos := context.Background(), newRepos(b)
+	user := createUser(b, repos, "alice")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		order := &entity.Order{ID: "order-" + strconv.Itoa(i), UserID: user.ID, Status: entity.StatusNew}
+		if err := repos.Orders.Create(ctx, order); err != nil {
+			b.Fatalf("Create: %v", err)
+		}
+	}
+}
+
+// benchCreateBatch uploads a batch of size new orders per iteration
+func benchCreateBatch(b *testing.B, newRepos Factory, size int) {
+	ctx, repos := context.Background(), newRepos(b)
+	user := createUser(b, repos, "alice")
+
+	ids := make([]string, size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range ids {
+			ids[j] = "batch-" + strconv.Itoa(i) + "-" + strconv.Itoa(j)
+		}
+		if _, err := repos.Orders.CreateBatch(ctx, user.ID, ids); err != nil {
+			b.Fatalf("CreateBatch: %v", err)
+		}
+	}
+}
+
+// benchListOrders reads the first page of a user with a thousand orders
+func benchListOrders(b *testing.B, newRepos Factory) {
+	ctx, repos := context.Background(), newRepos(b)
+	user := createUser(b, repos, "alice")
+
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = "order-" + strconv.Itoa(i)
+	}
+	if _, err := repos.Orders.CreateBatch(ctx, user.ID, ids); err != nil {
+		b.Fatalf("CreateBatch: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repos.Orders.List(ctx, repository.OrderFilter{UserID: user.ID, Limit: 50}); err != nil {
+			b.Fatalf("List: %v", err)
+		}
+	}
+}
+
+// benchUpdateBalance credits a user from parallel goroutines, which contend for the balance row
+func benchUpdateBalance(b *testing.B, newRepos Factory) {
+	ctx, repos := context.Background(), newRepos(b)
+	user := createUser(b, repos, "alice")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := repos.Balances.UpdateBalance(ctx, user.ID, 1, false); err != nil {
+				b.Errorf("UpdateBalance: %v", err)
+				return
+			}
+		}
+	})
+}
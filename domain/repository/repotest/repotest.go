@@ -0,0 +1,1039 @@
+// Package repotest is a conformance suite for implementations of the user, order, balance, withdrawal
+// and outbox repositories and of the Transactor. Every backend runs it from its own tests, so that the
+// backends stay interchangeable:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(tb testing.TB) repotest.Repositories {
+//			return repotest.Repositories{Users: memory.NewUserRepo(), ...}
+//		})
+//	}
+//
+// Bench measures the same operations, to compare backends with go test -bench.
+package repotest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Repositories are the repositories of one backend, sharing the same empty storage
+type Repositories struct {
+	Users       repository.UserRepository
+	Orders      repository.OrderRepository
+	Balances    repository.BalanceRepository
+	Withdrawals repository.WithdrawalRepository
+	Outbox      repository.OutboxRepository
+	Transactor  repository.Transactor
+}
+
+// Factory creates empty repositories for a single test or benchmark
+type Factory func(tb testing.TB) Repositories
+
+// Run runs the whole suite against the repositories created by newRepos
+func Run(t *testing.T, newRepos Factory) {
+	t.Run("Users", func(t *testing.T) { RunUsers(t, newRepos) })
+	t.Run("Orders", func(t *testing.T) { RunOrders(t, newRepos) })
+	t.Run("Balances", func(t *testing.T) { RunBalances(t, newRepos) })
+	t.Run("Withdrawals", func(t *testing.T) { RunWithdrawals(t, newRepos) })
+	t.Run("Outbox", func(t *testing.T) { RunOutbox(t, newRepos) })
+	t.Run("Transactions", func(t *testing.T) { RunTransactions(t, newRepos) })
+}
+
+// RunUsers checks the UserRepository semantics
+func RunUsers(t *testing.T, newRepos Factory) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+
+		user := &entity.User{Login: "alice", Password: "hash", Role: entity.RoleUser}
+		if err := repos.Users.Create(ctx, user); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if user.ID == 0 || user.CreatedAt.IsZero() {
+			t.Fatalf("Create did not set ID and CreatedAt: %+v", user)
+		}
+
+		byID, err := repos.Users.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		byLogin, err := repos.Users.GetByLogin(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetByLogin: %v", err)
+		}
+		for _, got := range []*entity.User{byID, byLogin} {
+			if got.ID != user.ID || got.Login != "alice" || got.Password != "hash" || got.Role != entity.RoleUser {
+				t.Errorf("got %+v, want the created user %+v", got, user)
+			}
+			if got.TokenVersion != 0 || got.TOTPEnabled || got.Blocked {
+				t.Errorf("new user %+v has non-default fields", got)
+			}
+		}
+	})
+
+	t.Run("UniqueLogin", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+
+		createUser(t, repos, "alice")
+		err := repos.Users.Create(ctx, &entity.User{Login: "alice", Password: "other", Role: entity.RoleUser})
+		if !errors.Is(err, repository.ErrAlreadyExists) {
+			t.Fatalf("Create with a taken login: got %v, want ErrAlreadyExists", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+
+		if _, err := repos.Users.GetByID(ctx, 12345); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID: got %v, want ErrNotFound", err)
+		}
+		if _, err := repos.Users.GetByLogin(ctx, "nobody"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByLogin: got %v, want ErrNotFound", err)
+		}
+		err := repos.Users.UpdatePassword(ctx, &entity.User{ID: 12345, Password: "hash"})
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("UpdatePassword: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Updates", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+
+		user := createUser(t, repos, "alice")
+
+		user.Password = "new-hash"
+		if err := repos.Users.UpdatePassword(ctx, user); err != nil {
+			t.Fatalf("UpdatePassword: %v", err)
+		}
+		if user.TokenVersion != 1 {
+			t.Errorf("UpdatePassword set TokenVersion %d, want 1", user.TokenVersion)
+		}
+
+		user.TOTPSecret, user.TOTPEnabled = "SECRET", true
+		if err := repos.Users.UpdateTOTP(ctx, user); err != nil {
+			t.Fatalf("UpdateTOTP: %v", err)
+		}
+		user.Role = entity.RoleAdmin
+		if err := repos.Users.UpdateRole(ctx, user); err != nil {
+			t.Fatalf("UpdateRole: %v", err)
+		}
+		user.Blocked = true
+		if err := repos.Users.UpdateBlocked(ctx, user); err != nil {
+			t.Fatalf("UpdateBlocked: %v", err)
+		}
+
+		got, err := repos.Users.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Password != "new-hash" || got.TokenVersion != 1 || got.TOTPSecret != "SECRET" || !got.TOTPEnabled ||
+			got.Role != entity.RoleAdmin || !got.Blocked {
+			t.Errorf("got %+v, want the updated user %+v", got, user)
+		}
+	})
+
+	t.Run("TOTPAttempts", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+
+		user := createUser(t, repos, "alice")
+
+		for i := 0; i < 3; i++ {
+			allowed, err := repos.Users.BeginTOTPAttempt(ctx, user.ID, 3, time.Hour)
+			if err != nil || !allowed {
+				t.Fatalf("BeginTOTPAttempt %d = %v, %v, want true", i+1, allowed, err)
+			}
+		}
+		if allowed, err := repos.Users.BeginTOTPAttempt(ctx, user.ID, 3, time.Hour); err != nil || allowed {
+			t.Fatalf("BeginTOTPAttempt after 3 failures = %v, %v, want false", allowed, err)
+		}
+		if allowed, err := repos.Users.BeginTOTPAttempt(ctx, user.ID, 3, 0); err != nil || !allowed {
+			t.Fatalf("BeginTOTPAttempt after the lockout = %v, %v, want true", allowed, err)
+		}
+
+		if accepted, err := repos.Users.AcceptTOTP(ctx, user.ID, 100); err != nil || !accepted {
+			t.Fatalf("AcceptTOTP(100) = %v, %v, want true", accepted, err)
+		}
+		for _, step := range []int64{100, 99} {
+			if accepted, err := repos.Users.AcceptTOTP(ctx, user.ID, step); err != nil || accepted {
+				t.Errorf("AcceptTOTP(%d) after step 100 = %v, %v, want false", step, accepted, err)
+			}
+		}
+		if accepted, err := repos.Users.AcceptTOTP(ctx, user.ID, 0); err != nil || !accepted {
+			t.Errorf("AcceptTOTP(0) = %v, %v, want true", accepted, err)
+		}
+
+		// Accepting a code clears the failures
+		for i := 0; i < 3; i++ {
+			if allowed, err := repos.Users.BeginTOTPAttempt(ctx, user.ID, 3, time.Hour); err != nil || !allowed {
+				t.Fatalf("BeginTOTPAttempt %d after AcceptTOTP = %v, %v, want true", i+1, allowed, err)
+			}
+		}
+	})
+
+	t.Run("SearchByLogin", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+
+		for _, login := range []string{"carol", "Alice", "bob", "alicia", "al%ce"} {
+			createUser(t, repos, login)
+		}
+
+		users, err := repos.Users.SearchByLogin(ctx, "ALI", 10)
+		if err != nil {
+			t.Fatalf("SearchByLogin: %v", err)
+		}
+		if got, want := logins(users), []string{"Alice", "alicia"}; !slices.Equal(got, want) {
+			t.Errorf("SearchByLogin(ALI) = %v, want %v", got, want)
+		}
+
+		users, err = repos.Users.SearchByLogin(ctx, "%", 10)
+		if err != nil {
+			t.Fatalf("SearchByLogin: %v", err)
+		}
+		if got, want := logins(users), []string{"al%ce"}; !slices.Equal(got, want) {
+			t.Errorf("SearchByLogin(%%) = %v, want wildcards matched literally %v", got, want)
+		}
+
+		users, err = repos.Users.SearchByLogin(ctx, "", 2)
+		if err != nil {
+			t.Fatalf("SearchByLogin: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("SearchByLogin with limit 2 returned %d users", len(users))
+		}
+	})
+}
+
+// RunOrders checks the OrderRepository semantics
+func RunOrders(t *testing.T, newRepos Factory) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		order := &entity.Order{ID: "12345678903", UserID: user.ID, Status: entity.StatusNew}
+		if err := repos.Orders.Create(ctx, order); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if order.UploadedAt.IsZero() {
+			t.Errorf("Create did not set UploadedAt")
+		}
+
+		got, err := repos.Orders.GetByID(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.ID != order.ID || got.UserID != user.ID || got.Status != entity.StatusNew || got.Accrual != 0 {
+			t.Errorf("got %+v, want the created order %+v", got, order)
+		}
+
+		exists, ownerID, err := repos.Orders.CheckExists(ctx, order.ID)
+		if err != nil || !exists || ownerID != user.ID {
+			t.Errorf("CheckExists = %v, %d, %v, want true, %d, nil", exists, ownerID, err, user.ID)
+		}
+
+		err = repos.Orders.Create(ctx, &entity.Order{ID: order.ID, UserID: user.ID, Status: entity.StatusNew})
+		if !errors.Is(err, repository.ErrAlreadyExists) {
+			t.Errorf("Create of an existing order: got %v, want ErrAlreadyExists", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+
+		if _, err := repos.Orders.GetByID(ctx, "0"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID: got %v, want ErrNotFound", err)
+		}
+		exists, ownerID, err := repos.Orders.CheckExists(ctx, "0")
+		if err != nil || exists || ownerID != 0 {
+			t.Errorf("CheckExists = %v, %d, %v, want false, 0, nil", exists, ownerID, err)
+		}
+	})
+
+	t.Run("CreateBatch", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		alice := createUser(t, repos, "alice")
+		bob := createUser(t, repos, "bob")
+
+		if err := repos.Orders.Create(ctx, &entity.Order{ID: "1", UserID: bob.ID, Status: entity.StatusNew}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		results, err := repos.Orders.CreateBatch(ctx, alice.ID, []string{"1", "2", "3", "2"})
+		if err != nil {
+			t.Fatalf("CreateBatch: %v", err)
+		}
+
+		// The order of the results is unspecified
+		slices.SortFunc(results, func(a, b repository.BatchInsertResult) int { return strings.Compare(a.ID, b.ID) })
+		want := []repository.BatchInsertResult{
+			{ID: "1", Inserted: false, OwnerID: bob.ID},
+			{ID: "2", Inserted: true},
+			{ID: "3", Inserted: true},
+		}
+		if !slices.Equal(results, want) {
+			t.Errorf("CreateBatch = %+v, want %+v", results, want)
+		}
+
+		order, err := repos.Orders.GetByID(ctx, "2")
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if order.UserID != alice.ID || order.Status != entity.StatusNew {
+			t.Errorf("batch inserted %+v, want a NEW order of user %d", order, alice.ID)
+		}
+	})
+
+	t.Run("UpdateStatus", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		order := &entity.Order{ID: "1", UserID: user.ID, Status: entity.StatusNew}
+		if err := repos.Orders.Create(ctx, order); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		order.Status, order.Accrual = entity.StatusProcessed, 500.5
+		updated, err := repos.Orders.UpdateStatus(ctx, order, entity.StatusProcessing)
+		if err != nil || updated {
+			t.Errorf("UpdateStatus from the wrong status = %v, %v, want false", updated, err)
+		}
+
+		updated, err = repos.Orders.UpdateStatus(ctx, order, entity.StatusNew)
+		if err != nil || !updated {
+			t.Fatalf("UpdateStatus = %v, %v, want true", updated, err)
+		}
+
+		got, err := repos.Orders.GetByID(ctx, "1")
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Status != entity.StatusProcessed || got.Accrual != 500.5 {
+			t.Errorf("got %+v, want status PROCESSED and accrual 500.5", got)
+		}
+
+		missing := &entity.Order{ID: "2", Status: entity.StatusProcessed}
+		if updated, err := repos.Orders.UpdateStatus(ctx, missing, entity.StatusNew); err != nil || updated {
+			t.Errorf("UpdateStatus of a missing order = %v, %v, want false", updated, err)
+		}
+	})
+
+	t.Run("ConcurrentUpdateStatus", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		if err := repos.Orders.Create(ctx, &entity.Order{ID: "1", UserID: user.ID, Status: entity.StatusProcessing}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var succeeded int
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				order := &entity.Order{ID: "1", Status: entity.StatusProcessed, Accrual: 10}
+				updated, err := repos.Orders.UpdateStatus(ctx, order, entity.StatusProcessing)
+				if err != nil {
+					t.Errorf("UpdateStatus: %v", err)
+					return
+				}
+				if updated {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != 1 {
+			t.Errorf("%d concurrent updates from PROCESSING to PROCESSED succeeded, want 1", succeeded)
+		}
+	})
+
+	t.Run("GetByUserID", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		alice := createUser(t, repos, "alice")
+		bob := createUser(t, repos, "bob")
+
+		for i := range 3 {
+			createOrder(t, repos, strconv.Itoa(i), alice.ID, entity.StatusNew)
+		}
+		createOrder(t, repos, "bob-order", bob.ID, entity.StatusNew)
+
+		orders, err := repos.Orders.GetByUserID(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetByUserID: %v", err)
+		}
+		if len(orders) != 3 {
+			t.Fatalf("GetByUserID returned %d orders, want 3", len(orders))
+		}
+		for i, order := range orders {
+			if order.UserID != alice.ID {
+				t.Errorf("GetByUserID returned an order of user %d", order.UserID)
+			}
+			if i > 0 && order.UploadedAt.After(orders[i-1].UploadedAt) {
+				t.Errorf("GetByUserID is not ordered newest first")
+			}
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		for i := range 5 {
+			status := entity.StatusNew
+			if i%2 == 1 {
+				status = entity.StatusProcessed
+			}
+			createOrder(t, repos, strconv.Itoa(i), user.ID, status)
+		}
+
+		for _, ascending := range []bool{false, true} {
+			var seen []entity.Order
+			filter := repository.OrderFilter{UserID: user.ID, Ascending: ascending, Limit: 2}
+			for page := 0; ; page++ {
+				orders, err := repos.Orders.List(ctx, filter)
+				if err != nil {
+					t.Fatalf("List: %v", err)
+				}
+				if len(orders) > 2 || page > 5 {
+					t.Fatalf("List returned %d orders on page %d, limit is 2", len(orders), page)
+				}
+				seen = append(seen, orders...)
+				if len(orders) < 2 {
+					break
+				}
+				last := orders[len(orders)-1]
+				filter.After = &repository.Cursor{Time: last.UploadedAt, ID: last.ID}
+			}
+
+			if len(seen) != 5 {
+				t.Errorf("pages (ascending %v) contain %d orders, want 5", ascending, len(seen))
+			}
+			for i := 1; i < len(seen); i++ {
+				c := seen[i].UploadedAt.Compare(seen[i-1].UploadedAt)
+				if c == 0 {
+					c = strings.Compare(seen[i].ID, seen[i-1].ID)
+				}
+				if (ascending && c <= 0) || (!ascending && c >= 0) {
+					t.Errorf("pages (ascending %v) are not ordered by upload time and ID: %v", ascending, orderIDs(seen))
+					break
+				}
+			}
+		}
+
+		orders, err := repos.Orders.List(ctx, repository.OrderFilter{
+			UserID:   user.ID,
+			Statuses: []string{entity.StatusProcessed},
+			Limit:    10,
+		})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(orders) != 2 {
+			t.Errorf("List of PROCESSED orders returned %v, want 2 orders", orderIDs(orders))
+		}
+	})
+
+	t.Run("GetByStatuses", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		alice := createUser(t, repos, "alice")
+		bob := createUser(t, repos, "bob")
+
+		// Orders of both users, created oldest first
+		createOrder(t, repos, "1", alice.ID, entity.StatusNew)
+		createOrder(t, repos, "2", bob.ID, entity.StatusProcessed)
+		createOrder(t, repos, "3", bob.ID, entity.StatusProcessing)
+		createOrder(t, repos, "4", alice.ID, entity.StatusInvalid)
+		createOrder(t, repos, "5", alice.ID, entity.StatusNew)
+
+		pending := []string{entity.StatusNew, entity.StatusProcessing}
+
+		orders, err := repos.Orders.GetByStatuses(ctx, pending, 2)
+		if err != nil {
+			t.Fatalf("GetByStatuses: %v", err)
+		}
+		if got := orderIDs(orders); !slices.Equal(got, []string{"1", "3"}) {
+			t.Errorf("GetByStatuses with limit 2 returned %v, want the oldest pending orders [1 3]", got)
+		}
+
+		count, err := repos.Orders.CountByStatuses(ctx, pending)
+		if err != nil {
+			t.Fatalf("CountByStatuses: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("CountByStatuses = %d, want 3", count)
+		}
+	})
+}
+
+// RunBalances checks the BalanceRepository semantics
+func RunBalances(t *testing.T, newRepos Factory) {
+	t.Run("GetOrCreate", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		for range 2 {
+			balance, err := repos.Balances.GetOrCreate(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("GetOrCreate: %v", err)
+			}
+			if balance.UserID != user.ID || balance.Current != 0 || balance.Withdrawn != 0 {
+				t.Errorf("GetOrCreate = %+v, want an empty balance", balance)
+			}
+		}
+	})
+
+	t.Run("UpdateBalance", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		// The first accrual creates the balance
+		if err := repos.Balances.UpdateBalance(ctx, user.ID, 100, false); err != nil {
+			t.Fatalf("UpdateBalance accrual: %v", err)
+		}
+		if err := repos.Balances.UpdateBalance(ctx, user.ID, 40.5, true); err != nil {
+			t.Fatalf("UpdateBalance withdrawal: %v", err)
+		}
+
+		err := repos.Balances.UpdateBalance(ctx, user.ID, 60, true)
+		if !errors.Is(err, repository.ErrInsufficientFunds) {
+			t.Errorf("UpdateBalance over the balance: got %v, want ErrInsufficientFunds", err)
+		}
+
+		assertBalance(t, repos, user.ID, 59.5, 40.5)
+	})
+
+	t.Run("ConcurrentWithdrawals", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		if err := repos.Balances.UpdateBalance(ctx, user.ID, 10, false); err != nil {
+			t.Fatalf("UpdateBalance: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var succeeded int
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := repos.Balances.UpdateBalance(ctx, user.ID, 1, true)
+				if err != nil && !errors.Is(err, repository.ErrInsufficientFunds) {
+					t.Errorf("UpdateBalance: %v", err)
+					return
+				}
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != 10 {
+			t.Errorf("%d withdrawals of 1 succeeded from a balance of 10", succeeded)
+		}
+		assertBalance(t, repos, user.ID, 0, 10)
+	})
+
+	t.Run("Adjust", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+		admin := createUser(t, repos, "admin")
+
+		err := repos.Balances.Adjust(ctx, &entity.BalanceAdjustment{UserID: user.ID, AdminID: admin.ID, Amount: 5, Reason: "gift"})
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Adjust without a balance: got %v, want ErrNotFound", err)
+		}
+
+		if _, err := repos.Balances.GetOrCreate(ctx, user.ID); err != nil {
+			t.Fatalf("GetOrCreate: %v", err)
+		}
+
+		credit := &entity.BalanceAdjustment{UserID: user.ID, AdminID: admin.ID, Amount: 30, Reason: "gift"}
+		if err := repos.Balances.Adjust(ctx, credit); err != nil {
+			t.Fatalf("Adjust credit: %v", err)
+		}
+		if credit.ID == 0 || credit.CreatedAt.IsZero() {
+			t.Errorf("Adjust did not set ID and CreatedAt: %+v", credit)
+		}
+
+		err = repos.Balances.Adjust(ctx, &entity.BalanceAdjustment{UserID: user.ID, AdminID: admin.ID, Amount: -31, Reason: "fix"})
+		if !errors.Is(err, repository.ErrInsufficientFunds) {
+			t.Errorf("Adjust below zero: got %v, want ErrInsufficientFunds", err)
+		}
+
+		debit := &entity.BalanceAdjustment{UserID: user.ID, AdminID: admin.ID, Amount: -10, Reason: "fix"}
+		if err := repos.Balances.Adjust(ctx, debit); err != nil {
+			t.Fatalf("Adjust debit: %v", err)
+		}
+
+		assertBalance(t, repos, user.ID, 20, 0)
+
+		adjustments, err := repos.Balances.GetAdjustmentsByUserID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetAdjustmentsByUserID: %v", err)
+		}
+		if len(adjustments) != 2 {
+			t.Fatalf("GetAdjustmentsByUserID returned %d adjustments, want 2", len(adjustments))
+		}
+		if adjustments[0].CreatedAt.Before(adjustments[1].CreatedAt) {
+			t.Errorf("GetAdjustmentsByUserID is not ordered newest first")
+		}
+		for _, a := range adjustments {
+			if a.UserID != user.ID || a.AdminID != admin.ID || (a.Amount != 30 && a.Amount != -10) {
+				t.Errorf("unexpected adjustment %+v", a)
+			}
+		}
+	})
+}
+
+// RunWithdrawals checks the WithdrawalRepository semantics
+func RunWithdrawals(t *testing.T, newRepos Factory) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		alice := createUser(t, repos, "alice")
+		bob := createUser(t, repos, "bob")
+
+		withdrawal := &entity.Withdrawal{UserID: alice.ID, OrderID: "2377225624", Sum: 751.5}
+		if err := repos.Withdrawals.Create(ctx, withdrawal); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if withdrawal.ID == 0 || withdrawal.ProcessedAt.IsZero() {
+			t.Errorf("Create did not set ID and ProcessedAt: %+v", withdrawal)
+		}
+		if err := repos.Withdrawals.Create(ctx, &entity.Withdrawal{UserID: bob.ID, OrderID: "1", Sum: 1}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		withdrawals, err := repos.Withdrawals.GetByUserID(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetByUserID: %v", err)
+		}
+		if len(withdrawals) != 1 {
+			t.Fatalf("GetByUserID returned %d withdrawals, want 1", len(withdrawals))
+		}
+		got := withdrawals[0]
+		if got.ID != withdrawal.ID || got.OrderID != "2377225624" || got.Sum != 751.5 {
+			t.Errorf("got %+v, want the created withdrawal %+v", got, withdrawal)
+		}
+
+		withdrawals, err = repos.Withdrawals.GetByUserID(ctx, 12345)
+		if err != nil || len(withdrawals) != 0 {
+			t.Errorf("GetByUserID of a user without withdrawals = %v, %v, want none", withdrawals, err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		for i := range 5 {
+			withdrawal := &entity.Withdrawal{UserID: user.ID, OrderID: strconv.Itoa(i), Sum: float64(i + 1)}
+			if err := repos.Withdrawals.Create(ctx, withdrawal); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+
+		for _, ascending := range []bool{false, true} {
+			var seen []entity.Withdrawal
+			filter := repository.WithdrawalFilter{UserID: user.ID, Ascending: ascending, Limit: 2}
+			for page := 0; ; page++ {
+				withdrawals, err := repos.Withdrawals.List(ctx, filter)
+				if err != nil {
+					t.Fatalf("List: %v", err)
+				}
+				if len(withdrawals) > 2 || page > 5 {
+					t.Fatalf("List returned %d withdrawals on page %d, limit is 2", len(withdrawals), page)
+				}
+				seen = append(seen, withdrawals...)
+				if len(withdrawals) < 2 {
+					break
+				}
+				last := withdrawals[len(withdrawals)-1]
+				filter.After = &repository.Cursor{Time: last.ProcessedAt, ID: strconv.FormatInt(last.ID, 10)}
+			}
+
+			if len(seen) != 5 {
+				t.Errorf("pages (ascending %v) contain %d withdrawals, want 5", ascending, len(seen))
+			}
+			for i := 1; i < len(seen); i++ {
+				c := seen[i].ProcessedAt.Compare(seen[i-1].ProcessedAt)
+				if c == 0 {
+					c = cmp.Compare(seen[i].ID, seen[i-1].ID)
+				}
+				if (ascending && c <= 0) || (!ascending && c >= 0) {
+					t.Errorf("pages (ascending %v) are not ordered by processing time and ID", ascending)
+					break
+				}
+			}
+		}
+	})
+}
+
+// RunOutbox checks the OutboxRepository semantics
+func RunOutbox(t *testing.T, newRepos Factory) {
+	t.Run("ClaimUnpublished", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+		addEvents(t, repos, user.ID, 3)
+
+		claimed, err := repos.Outbox.ClaimUnpublished(ctx, 2, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimUnpublished: %v", err)
+		}
+		if len(claimed) != 2 || claimed[0].ID >= claimed[1].ID {
+			t.Fatalf("ClaimUnpublished returned %v, want the 2 oldest events in order", eventIDs(claimed))
+		}
+		for _, event := range claimed {
+			if event.Type != "test" || event.UserID != user.ID || event.CreatedAt.IsZero() {
+				t.Errorf("claimed event %+v, want the recorded one", event)
+			}
+		}
+
+		// Claimed events are hidden from other claims until they are released
+		others, err := repos.Outbox.ClaimUnpublished(ctx, 10, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimUnpublished: %v", err)
+		}
+		if len(others) != 1 || others[0].ID <= claimed[1].ID {
+			t.Fatalf("second ClaimUnpublished returned %v, want only the unclaimed event", eventIDs(others))
+		}
+
+		if err := repos.Outbox.ReleaseClaims(ctx, []int64{claimed[1].ID}); err != nil {
+			t.Fatalf("ReleaseClaims: %v", err)
+		}
+		if err := repos.Outbox.RecordFailure(ctx, claimed[1].ID, "unavailable"); err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+
+		released, err := repos.Outbox.ClaimUnpublished(ctx, 10, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimUnpublished: %v", err)
+		}
+		if len(released) != 1 || released[0].ID != claimed[1].ID {
+			t.Fatalf("ClaimUnpublished after release returned %v, want the released event", eventIDs(released))
+		}
+		if released[0].Attempts != 1 || released[0].LastError != "unavailable" {
+			t.Errorf("released event has %d attempts and error %q, want the recorded failure", released[0].Attempts, released[0].LastError)
+		}
+	})
+
+	t.Run("MarkPublished", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+		addEvents(t, repos, user.ID, 2)
+
+		claimed, err := repos.Outbox.ClaimUnpublished(ctx, 1, time.Minute)
+		if err != nil || len(claimed) != 1 {
+			t.Fatalf("ClaimUnpublished returned %d events, %v, want 1", len(claimed), err)
+		}
+		if err := repos.Outbox.MarkPublished(ctx, []int64{claimed[0].ID}); err != nil {
+			t.Fatalf("MarkPublished: %v", err)
+		}
+
+		// Published events are not claimed again, even when their claim is released
+		if err := repos.Outbox.ReleaseClaims(ctx, []int64{claimed[0].ID}); err != nil {
+			t.Fatalf("ReleaseClaims: %v", err)
+		}
+		rest, err := repos.Outbox.ClaimUnpublished(ctx, 10, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimUnpublished: %v", err)
+		}
+		if len(rest) != 1 || rest[0].ID == claimed[0].ID {
+			t.Errorf("ClaimUnpublished returned %v, want only the unpublished event", eventIDs(rest))
+		}
+	})
+
+	t.Run("FanOut", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+		addEvents(t, repos, user.ID, 3)
+
+		events, err := repos.Outbox.GetNotFannedOut(ctx, 2)
+		if err != nil {
+			t.Fatalf("GetNotFannedOut: %v", err)
+		}
+		if len(events) != 2 || events[0].ID >= events[1].ID {
+			t.Fatalf("GetNotFannedOut returned %v, want the 2 oldest events in order", eventIDs(events))
+		}
+		if err := repos.Outbox.MarkFannedOut(ctx, eventIDs(events)); err != nil {
+			t.Fatalf("MarkFannedOut: %v", err)
+		}
+
+		rest, err := repos.Outbox.GetNotFannedOut(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetNotFannedOut: %v", err)
+		}
+		if len(rest) != 1 || rest[0].ID <= events[1].ID {
+			t.Errorf("GetNotFannedOut after MarkFannedOut returned %v, want only the newest event", eventIDs(rest))
+		}
+
+		// Publishing and fan-out are independent
+		claimed, err := repos.Outbox.ClaimUnpublished(ctx, 10, time.Minute)
+		if err != nil || len(claimed) != 3 {
+			t.Errorf("ClaimUnpublished returned %d events, %v, want all 3", len(claimed), err)
+		}
+	})
+
+	t.Run("DeletePublished", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+		addEvents(t, repos, user.ID, 3)
+
+		claimed, err := repos.Outbox.ClaimUnpublished(ctx, 10, time.Minute)
+		if err != nil || len(claimed) != 3 {
+			t.Fatalf("ClaimUnpublished returned %d events, %v, want 3", len(claimed), err)
+		}
+
+		// The first event is published and fanned out, the second only published, the third only fanned out
+		if err := repos.Outbox.MarkPublished(ctx, []int64{claimed[0].ID, claimed[1].ID}); err != nil {
+			t.Fatalf("MarkPublished: %v", err)
+		}
+		if err := repos.Outbox.MarkFannedOut(ctx, []int64{claimed[0].ID, claimed[2].ID}); err != nil {
+			t.Fatalf("MarkFannedOut: %v", err)
+		}
+
+		deleted, err := repos.Outbox.DeletePublished(ctx, time.Now().Add(-time.Hour))
+		if err != nil || deleted != 0 {
+			t.Errorf("DeletePublished of an hour ago deleted %d events, %v, want none", deleted, err)
+		}
+
+		deleted, err = repos.Outbox.DeletePublished(ctx, time.Now().Add(time.Hour))
+		if err != nil || deleted != 1 {
+			t.Fatalf("DeletePublished deleted %d events, %v, want the published and fanned out one", deleted, err)
+		}
+
+		rest, err := repos.Outbox.GetNotFannedOut(ctx, 10)
+		if err != nil || len(rest) != 1 || rest[0].ID != claimed[1].ID {
+			t.Errorf("GetNotFannedOut after DeletePublished returned %v, %v, want the event that was only published", eventIDs(rest), err)
+		}
+	})
+}
+
+// RunTransactions checks that the Transactor commits and rolls back the changes of all repositories
+func RunTransactions(t *testing.T, newRepos Factory) {
+	t.Run("Commit", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		err := repos.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := repos.Orders.Create(ctx, &entity.Order{ID: "1", UserID: user.ID, Status: entity.StatusNew}); err != nil {
+				return err
+			}
+
+			// Calls within the transaction see its changes
+			if _, err := repos.Orders.GetByID(ctx, "1"); err != nil {
+				return err
+			}
+
+			if err := repos.Balances.UpdateBalance(ctx, user.ID, 10, false); err != nil {
+				return err
+			}
+			return repos.Outbox.Add(ctx, entity.OutboxEvent{Type: "test", UserID: user.ID, Payload: []byte(`{}`)})
+		})
+		if err != nil {
+			t.Fatalf("WithinTransaction: %v", err)
+		}
+
+		if _, err := repos.Orders.GetByID(ctx, "1"); err != nil {
+			t.Errorf("GetByID after commit: %v", err)
+		}
+		assertBalance(t, repos, user.ID, 10, 0)
+		assertEventCount(t, repos, 1)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+		if err := repos.Balances.UpdateBalance(ctx, user.ID, 10, false); err != nil {
+			t.Fatalf("UpdateBalance: %v", err)
+		}
+
+		errFailed := errors.New("failed")
+		err := repos.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := repos.Orders.Create(ctx, &entity.Order{ID: "1", UserID: user.ID, Status: entity.StatusNew}); err != nil {
+				return err
+			}
+			if err := repos.Balances.UpdateBalance(ctx, user.ID, 5, true); err != nil {
+				return err
+			}
+			if err := repos.Withdrawals.Create(ctx, &entity.Withdrawal{UserID: user.ID, OrderID: "2", Sum: 5}); err != nil {
+				return err
+			}
+			if err := repos.Outbox.Add(ctx, entity.OutboxEvent{Type: "test", UserID: user.ID, Payload: []byte(`{}`)}); err != nil {
+				return err
+			}
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Fatalf("WithinTransaction returned %v, want the error of the function", err)
+		}
+
+		if _, err := repos.Orders.GetByID(ctx, "1"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID after rollback: got %v, want ErrNotFound", err)
+		}
+		withdrawals, err := repos.Withdrawals.GetByUserID(ctx, user.ID)
+		if err != nil || len(withdrawals) != 0 {
+			t.Errorf("GetByUserID after rollback returned %d withdrawals, %v, want none", len(withdrawals), err)
+		}
+		assertBalance(t, repos, user.ID, 10, 0)
+		assertEventCount(t, repos, 0)
+	})
+
+	t.Run("RollbackKeepsConcurrentWrites", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		// A write outside of the transaction, made while it runs, survives its rollback
+		errFailed := errors.New("failed")
+		done := make(chan error, 1)
+		err := repos.Transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+			if err := repos.Orders.Create(txCtx, &entity.Order{ID: "1", UserID: user.ID, Status: entity.StatusNew}); err != nil {
+				return err
+			}
+			go func() {
+				done <- repos.Orders.Create(ctx, &entity.Order{ID: "2", UserID: user.ID, Status: entity.StatusNew})
+			}()
+			time.Sleep(50 * time.Millisecond)
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Fatalf("WithinTransaction returned %v, want the error of the function", err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("Create outside of the transaction: %v", err)
+		}
+
+		if _, err := repos.Orders.GetByID(ctx, "1"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID of the rolled back order: got %v, want ErrNotFound", err)
+		}
+		if _, err := repos.Orders.GetByID(ctx, "2"); err != nil {
+			t.Errorf("GetByID of the order created outside of the transaction: %v", err)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		ctx, repos := context.Background(), newRepos(t)
+		user := createUser(t, repos, "alice")
+
+		// The inner call joins the outer transaction, so its changes are rolled back with it
+		errFailed := errors.New("failed")
+		err := repos.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			err := repos.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				return repos.Orders.Create(ctx, &entity.Order{ID: "1", UserID: user.ID, Status: entity.StatusNew})
+			})
+			if err != nil {
+				return err
+			}
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Fatalf("WithinTransaction returned %v, want the error of the function", err)
+		}
+
+		if _, err := repos.Orders.GetByID(ctx, "1"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID after rollback of the outer transaction: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// createUser creates a user with the login
+func createUser(t testing.TB, repos Repositories, login string) *entity.User {
+	t.Helper()
+
+	user := &entity.User{Login: login, Password: "hash", Role: entity.RoleUser}
+	if err := repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user %q: %v", login, err)
+	}
+	return user
+}
+
+// createOrder creates an order of the user
+func createOrder(t testing.TB, repos Repositories, id string, userID int64, status string) {
+	t.Helper()
+
+	order := &entity.Order{ID: id, UserID: userID, Status: status}
+	if err := repos.Orders.Create(context.Background(), order); err != nil {
+		t.Fatalf("failed to create order %q: %v", id, err)
+	}
+}
+
+// addEvents records count outbox events of the user
+func addEvents(t testing.TB, repos Repositories, userID int64, count int) {
+	t.Helper()
+
+	events := make([]entity.OutboxEvent, count)
+	for i := range events {
+		events[i] = entity.OutboxEvent{Type: "test", UserID: userID, Payload: []byte(`{"n":` + strconv.Itoa(i) + `}`)}
+	}
+	if err := repos.Outbox.Add(context.Background(), events...); err != nil {
+		t.Fatalf("failed to add outbox events: %v", err)
+	}
+}
+
+// assertEventCount checks the number of outbox events, claiming them all
+func assertEventCount(t *testing.T, repos Repositories, count int) {
+	t.Helper()
+
+	events, err := repos.Outbox.ClaimUnpublished(context.Background(), 100, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimUnpublished: %v", err)
+	}
+	if len(events) != count {
+		t.Errorf("outbox has %d events, want %d", len(events), count)
+	}
+}
+
+// assertBalance checks the current and withdrawn points of the user
+func assertBalance(t *testing.T, repos Repositories, userID int64, current, withdrawn float64) {
+	t.Helper()
+
+	balance, err := repos.Balances.GetOrCreate(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetOrCreate: %v", err)
+	}
+	if balance.Current != current || balance.Withdrawn != withdrawn {
+		t.Errorf("balance is %v current, %v withdrawn, want %v and %v", balance.Current, balance.Withdrawn, current, withdrawn)
+	}
+}
+
+// logins lists the logins of users
+func logins(users []entity.User) []string {
+	names := make([]string, len(users))
+	for i, user := range users {
+		names[i] = user.Login
+	}
+	return names
+}
+
+// orderIDs lists the IDs of orders
+func orderIDs(orders []entity.Order) []string {
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+	return ids
+}
+
+// eventIDs lists the IDs of outbox events
+func eventIDs(events []entity.OutboxEvent) []int64 {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
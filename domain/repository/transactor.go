@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// Transactor runs a function within a transaction shared by the repositories.
+// Repository calls made with the context passed to fn take part in the transaction,
+// which is committed when fn returns nil and rolled back otherwise.
+// Nested calls join the outer transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
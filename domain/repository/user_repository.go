@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"gophermart/domain/entity"
+	"time"
 )
 
 // UserRepository defines methods to work with users
@@ -10,4 +11,15 @@ type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) error
 	GetByLogin(ctx context.Context, login string) (*entity.User, error)
 	GetByID(ctx context.Context, id int64) (*entity.User, error)
+	UpdatePassword(ctx context.Context, user *entity.User) error
+	UpdateTOTP(ctx context.Context, user *entity.User) error
+	// BeginTOTPAttempt counts a second factor attempt, it reports false without counting while the user
+	// is locked out after maxFailures attempts without success, until lockout has passed since the last one
+	BeginTOTPAttempt(ctx context.Context, userID int64, maxFailures int, lockout time.Duration) (bool, error)
+	// AcceptTOTP clears the attempt count and stores the time step of the accepted TOTP code, it reports
+	// false if a code of the same or a later step was accepted before. Step 0 only clears the count.
+	AcceptTOTP(ctx context.Context, userID int64, step int64) (bool, error)
+	UpdateRole(ctx context.Context, user *entity.User) error
+	UpdateBlocked(ctx context.Context, user *entity.User) error
+	SearchByLogin(ctx context.Context, query string, limit int) ([]entity.User, error)
 }
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"time"
+)
+
+// WebhookRepository defines methods to work with webhook subscriptions and their delivery queue
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *entity.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id int64) (*entity.WebhookSubscription, error)
+	GetSubscriptionsByUserID(ctx context.Context, userID int64) ([]entity.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+
+	// Enqueue queues a delivery of the payload to every subscription of the user to the event type
+	Enqueue(ctx context.Context, userID int64, eventType string, payload []byte) (int64, error)
+	// ClaimDue leases up to limit pending deliveries that are due by postponing them by lease
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]entity.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, id int64) (*entity.WebhookDelivery, error)
+	GetDeliveriesBySubscriptionID(ctx context.Context, subscriptionID int64, limit int) ([]entity.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error
+}
@@ -9,4 +9,5 @@ import (
 type WithdrawalRepository interface {
 	Create(ctx context.Context, withdrawal *entity.Withdrawal) error
 	GetByUserID(ctx context.Context, userID int64) ([]entity.Withdrawal, error)
+	List(ctx context.Context, filter WithdrawalFilter) ([]entity.Withdrawal, error)
 }
@@ -6,11 +6,23 @@ import (
 	"fmt"
 	"gophermart/domain/entity"
 	"gophermart/domain/repository"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// accrualBatchSize is the maximum number of orders checked in one poll
+const accrualBatchSize = 100
+
+// pendingStatuses are the statuses of orders whose accrual is not final yet
+var pendingStatuses = []string{entity.StatusNew, entity.StatusProcessing}
+
 // AccrualResponse represents the response from the accrual system
 type AccrualResponse struct {
 	Order   string  `json:"order"`
@@ -21,22 +33,35 @@ type AccrualResponse struct {
 // AccrualService handles interaction with the accrual system
 type AccrualService struct {
 	orderRepo    repository.OrderRepository
+	orderService *OrderService
 	accrualURL   string
 	client       *http.Client
 	pollInterval time.Duration
+	metrics      MetricsRecorder
+	logger       *slog.Logger
 	stopCh       chan struct{}
 	wg           sync.WaitGroup
 }
 
 // NewAccrualService creates a new AccrualService
-func NewAccrualService(orderRepo repository.OrderRepository, accrualURL string, pollInterval time.Duration) *AccrualService {
+func NewAccrualService(
+	orderRepo repository.OrderRepository,
+	orderService *OrderService,
+	accrualURL string,
+	pollInterval time.Duration,
+	metrics MetricsRecorder,
+	logger *slog.Logger,
+) *AccrualService {
 	return &AccrualService{
-		orderRepo:  orderRepo,
-		accrualURL: accrualURL,
+		orderRepo:    orderRepo,
+		orderService: orderService,
+		accrualURL:   accrualURL,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 		pollInterval: pollInterval,
+		metrics:      metrics,
+		logger:       logger,
 		stopCh:       make(chan struct{}),
 	}
 }
@@ -72,12 +97,22 @@ func (s *AccrualService) pollAccrualSystem(ctx context.Context) {
 	}
 }
 
-// processNewOrders processes all new orders
+// processNewOrders checks the oldest orders that are not final yet with the accrual system
 func (s *AccrualService) processNewOrders(ctx context.Context) {
-	// Get all orders with status NEW or PROCESSING
+	ctx, span := tracer.Start(ctx, "AccrualService.processNewOrders")
+	defer span.End()
+
+	start := time.Now()
+
+	pending, err := s.orderRepo.CountByStatuses(ctx, pendingStatuses)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to count pending orders", "error", err)
+		return
+	}
+
 	orders, err := s.getOrdersToProcess(ctx)
 	if err != nil {
-		fmt.Printf("Failed to get orders to process: %v\n", err)
+		s.logger.ErrorContext(ctx, "Failed to get orders to process", "error", err)
 		return
 	}
 
@@ -85,58 +120,87 @@ func (s *AccrualService) processNewOrders(ctx context.Context) {
 	for _, order := range orders {
 		status, accrual, err := s.checkOrderStatus(ctx, order.ID)
 		if err != nil {
-			fmt.Printf("Failed to check order status for order %s: %v\n", order.ID, err)
+			s.logger.WarnContext(ctx, "Failed to check order status", "order_id", order.ID, "error", err)
 			continue
 		}
 
-		// Update order status if changed
+		// Update order status if changed, the order service credits the balance of processed orders
 		if status != order.Status {
-			err := s.updateOrderStatus(ctx, order.ID, status, accrual)
+			err := s.orderService.UpdateOrderStatus(ctx, order.ID, status, accrual)
 			if err != nil {
-				fmt.Printf("Failed to update order status for order %s: %v\n", order.ID, err)
+				s.logger.ErrorContext(ctx, "Failed to update order status", "order_id", order.ID, "error", err)
 			}
 		}
 	}
+
+	s.metrics.ObservePollCycle(time.Since(start), pending)
 }
 
-// getOrdersToProcess retrieves all orders that need processing
+// getOrdersToProcess retrieves the oldest orders with status NEW or PROCESSING
 func (s *AccrualService) getOrdersToProcess(ctx context.Context) ([]entity.Order, error) {
-	// This is a simplified implementation. In a real system, you would need to:
-	// 1. Query the database for orders with status NEW or PROCESSING
-	// 2. Implement pagination or batching for large datasets
-	// 3. Handle rate limiting for the accrual system
+	orders, err := s.orderRepo.GetByStatuses(ctx, pendingStatuses, accrualBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending orders: %w", err)
+	}
 
-	// For this example, just returning an empty slice
-	return []entity.Order{}, nil
+	return orders, nil
 }
 
 // checkOrderStatus checks the status of an order in the accrual system
 func (s *AccrualService) checkOrderStatus(ctx context.Context, orderID string) (string, float64, error) {
 	url := fmt.Sprintf("%s/api/orders/%s", s.accrualURL, orderID)
 
+	ctx, span := tracer.Start(ctx, "GET /api/orders/{number}",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("url.full", url),
+			attribute.String("order_id", orderID),
+		),
+	)
+	defer span.End()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to create request: %w", err)
 	}
+	// The accrual system continues the trace from the traceparent header
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
+	start := time.Now()
 	resp, err := s.client.Do(req)
+	latency := time.Since(start)
 	if err != nil {
+		s.metrics.ObserveAccrualCall(AccrualOutcomeError, latency)
+		recordSpanError(span, err)
 		return "", 0, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
 	// Handle different response codes
 	switch resp.StatusCode {
 	case http.StatusOK:
 		// Process successful response
 		var accrualResp AccrualResponse
 		if err := json.NewDecoder(resp.Body).Decode(&accrualResp); err != nil {
+			s.metrics.ObserveAccrualCall(AccrualOutcomeError, latency)
+			recordSpanError(span, err)
 			return "", 0, fmt.Errorf("failed to decode response: %w", err)
 		}
 
+		s.metrics.ObserveAccrualCall(AccrualOutcomeOK, latency)
+
+		// The accrual system reports registered orders that it has not started processing yet
+		if accrualResp.Status == "REGISTERED" {
+			return entity.StatusProcessing, 0, nil
+		}
 		return accrualResp.Status, accrualResp.Accrual, nil
 
 	case http.StatusTooManyRequests:
+		s.metrics.ObserveAccrualCall(AccrualOutcomeRateLimited, latency)
+
 		// Handle rate limiting
 		retryAfter := resp.Header.Get("Retry-After")
 		if retryAfter != "" {
@@ -147,35 +211,43 @@ func (s *AccrualService) checkOrderStatus(ctx context.Context, orderID string) (
 			}
 		}
 
-		return "", 0, fmt.Errorf("rate limited by accrual system")
+		err := fmt.Errorf("rate limited by accrual system")
+		recordSpanError(span, err)
+		return "", 0, err
 
 	case http.StatusNoContent:
 		// Order not found in accrual system
+		s.metrics.ObserveAccrualCall(AccrualOutcomeNotRegistered, latency)
 		return entity.StatusInvalid, 0, nil
 
 	default:
-		return "", 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		s.metrics.ObserveAccrualCall(AccrualOutcomeError, latency)
+		err := fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		recordSpanError(span, err)
+		return "", 0, err
 	}
 }
 
-// updateOrderStatus updates the status of an order
-func (s *AccrualService) updateOrderStatus(ctx context.Context, orderID, status string, accrual float64) error {
-	order, err := s.orderRepo.GetByID(ctx, orderID)
+// CheckOrderDirectly checks the status of an order directly (can be called from API)
+func (s *AccrualService) CheckOrderDirectly(ctx context.Context, orderID string) (string, float64, error) {
+	ctx, span := tracer.Start(ctx, "AccrualService.CheckOrderDirectly")
+	defer span.End()
+
+	return s.checkOrderStatus(ctx, orderID)
+}
+
+// CheckReachable reports whether the accrual system answers HTTP requests, whatever the status code
+func (s *AccrualService) CheckReachable(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.accrualURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get order: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	order.Status = status
-	order.Accrual = accrual
-
-	if err := s.orderRepo.Update(ctx, order); err != nil {
-		return fmt.Errorf("failed to update order: %w", err)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("accrual system unreachable: %w", err)
 	}
+	resp.Body.Close()
 
 	return nil
 }
-
-// CheckOrderDirectly checks the status of an order directly (can be called from API)
-func (s *AccrualService) CheckOrderDirectly(ctx context.Context, orderID string) (string, float64, error) {
-	return s.checkOrderStatus(ctx, orderID)
-}
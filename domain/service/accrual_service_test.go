@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"gophermart/domain/entity"
+	"gophermart/internal/sqlite"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metricsStub records the measurements the tests look at
+type metricsStub struct {
+	pendingOrders []int
+	pointsAccrued float64
+}
+
+func (m *metricsStub) ObserveAccrualCall(string, time.Duration) {}
+
+func (m *metricsStub) ObservePollCycle(_ time.Duration, pendingOrders int) {
+	m.pendingOrders = append(m.pendingOrders, pendingOrders)
+}
+
+func (m *metricsStub) AddPointsAccrued(points float64) {
+	m.pointsAccrued += points
+}
+
+func (m *metricsStub) AddPointsWithdrawn(float64) {}
+
+func TestProcessNewOrdersCreditsProcessedOrders(t *testing.T) {
+	ctx := context.Background()
+
+	accrual := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		number := strings.TrimPrefix(r.URL.Path, "/api/orders/")
+		switch number {
+		case "79927398713":
+			json.NewEncoder(w).Encode(AccrualResponse{Order: number, Status: entity.StatusProcessed, Accrual: 25})
+		case "12345678903":
+			json.NewEncoder(w).Encode(AccrualResponse{Order: number, Status: "REGISTERED"})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer accrual.Close()
+
+	db := newTestDB(t)
+	user := &entity.User{Login: "alice", Password: "hash", Role: entity.RoleUser}
+	if err := sqlite.NewUserRepo(db).Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	orderRepo := sqlite.NewOrderRepo(db)
+	balanceRepo := sqlite.NewBalanceRepo(db)
+	for _, number := range []string{"79927398713", "12345678903", "0"} {
+		if err := orderRepo.Create(ctx, &entity.Order{ID: number, UserID: user.ID, Status: entity.StatusNew}); err != nil {
+			t.Fatalf("failed to create order: %v", err)
+		}
+	}
+
+	metrics := &metricsStub{}
+	orderService := NewOrderService(
+		orderRepo,
+		balanceRepo,
+		sqlite.NewOutboxRepo(db),
+		sqlite.NewTransactor(db),
+		NewEventBus(DefaultEventHistory),
+		metrics,
+		discardLogger(),
+	)
+	accrualService := NewAccrualService(orderRepo, orderService, accrual.URL, time.Minute, metrics, discardLogger())
+
+	accrualService.processNewOrders(ctx)
+
+	want := map[string]string{
+		"79927398713": entity.StatusProcessed,
+		"12345678903": entity.StatusProcessing,
+		"0":           entity.StatusInvalid,
+	}
+	for number, status := range want {
+		order, err := orderRepo.GetByID(ctx, number)
+		if err != nil {
+			t.Fatalf("failed to get order %s: %v", number, err)
+		}
+		if order.Status != status {
+			t.Errorf("order %s is %s, want %s", number, order.Status, status)
+		}
+	}
+
+	balance, err := balanceRepo.GetOrCreate(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if balance.Current != 25 {
+		t.Errorf("balance is %v, want the accrual of the processed order", balance.Current)
+	}
+	if metrics.pointsAccrued != 25 {
+		t.Errorf("points accrued = %v, want 25", metrics.pointsAccrued)
+	}
+
+	// The order that is still processing is pending on the next poll
+	accrualService.processNewOrders(ctx)
+	if got := metrics.pendingOrders; len(got) != 2 || got[0] != 3 || got[1] != 1 {
+		t.Errorf("pending orders per poll = %v, want [3 1]", got)
+	}
+}
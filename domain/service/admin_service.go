@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"log/slog"
+	"strings"
+)
+
+// maxUserSearchResults limits the number of users returned by a login search
+const maxUserSearchResults = 50
+
+// UserOverview aggregates everything support staff need to answer questions about a user
+type UserOverview struct {
+	User        *entity.User               `json:"user"`
+	Balance     *entity.Balance            `json:"balance"`
+	Orders      []entity.Order             `json:"orders"`
+	Withdrawals []entity.Withdrawal        `json:"withdrawals"`
+	Adjustments []entity.BalanceAdjustment `json:"adjustments"`
+}
+
+// AdminService handles back-office operations
+type AdminService struct {
+	userRepo       repository.UserRepository
+	orderRepo      repository.OrderRepository
+	balanceRepo    repository.BalanceRepository
+	withdrawalRepo repository.WithdrawalRepository
+	outboxRepo     repository.OutboxRepository
+	transactor     repository.Transactor
+	orderService   *OrderService
+	accrualService *AccrualService
+	events         *EventBus
+	logger         *slog.Logger
+}
+
+// NewAdminService creates a new AdminService
+func NewAdminService(
+	userRepo repository.UserRepository,
+	orderRepo repository.OrderRepository,
+	balanceRepo repository.BalanceRepository,
+	withdrawalRepo repository.WithdrawalRepository,
+	outboxRepo repository.OutboxRepository,
+	transactor repository.Transactor,
+	orderService *OrderService,
+	accrualService *AccrualService,
+	events *EventBus,
+	logger *slog.Logger,
+) *AdminService {
+	return &AdminService{
+		userRepo:       userRepo,
+		orderRepo:      orderRepo,
+		balanceRepo:    balanceRepo,
+		withdrawalRepo: withdrawalRepo,
+		outboxRepo:     outboxRepo,
+		transactor:     transactor,
+		orderService:   orderService,
+		accrualService: accrualService,
+		events:         events,
+		logger:         logger,
+	}
+}
+
+// SearchUsers finds users whose login contains the query
+func (s *AdminService) SearchUsers(ctx context.Context, query string) ([]entity.User, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.SearchUsers")
+	defer span.End()
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrSearchQueryRequired
+	}
+
+	return s.userRepo.SearchByLogin(ctx, query, maxUserSearchResults)
+}
+
+// GetUserOverview retrieves a user with their balance, orders, withdrawals and adjustments
+func (s *AdminService) GetUserOverview(ctx context.Context, userID int64) (*UserOverview, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.GetUserOverview")
+	defer span.End()
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.balanceRepo.GetOrCreate(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	orders, err := s.orderRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+
+	withdrawals, err := s.withdrawalRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get withdrawals: %w", err)
+	}
+
+	adjustments, err := s.balanceRepo.GetAdjustmentsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjustments: %w", err)
+	}
+
+	return &UserOverview{
+		User:        user,
+		Balance:     balance,
+		Orders:      orders,
+		Withdrawals: withdrawals,
+		Adjustments: adjustments,
+	}, nil
+}
+
+// RecheckOrder asks the accrual system for the current state of an order and applies it
+func (s *AdminService) RecheckOrder(ctx context.Context, orderID string) (*entity.Order, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.RecheckOrder")
+	defer span.End()
+
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	status, accrual, err := s.accrualService.CheckOrderDirectly(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAccrualUnavailable, err)
+	}
+
+	if err := s.orderService.UpdateOrderStatus(ctx, orderID, status, accrual); err != nil {
+		return nil, err
+	}
+
+	return s.orderRepo.GetByID(ctx, orderID)
+}
+
+// AdjustBalance credits or debits a user's balance with a mandatory reason
+func (s *AdminService) AdjustBalance(
+	ctx context.Context,
+	adminID, userID int64,
+	amount float64,
+	reason string,
+) (*entity.BalanceAdjustment, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.AdjustBalance")
+	defer span.End()
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	if amount == 0 {
+		return nil, ErrZeroAmount
+	}
+
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	// Make sure the balance row exists before locking it
+	if _, err := s.balanceRepo.GetOrCreate(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	adjustment := &entity.BalanceAdjustment{
+		UserID:  userID,
+		AdminID: adminID,
+		Amount:  amount,
+		Reason:  reason,
+	}
+
+	var event Event
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.balanceRepo.Adjust(ctx, adjustment); err != nil {
+			return fmt.Errorf("failed to adjust balance: %w", err)
+		}
+
+		balance, err := balanceEvent(ctx, s.balanceRepo, userID)
+		if err != nil {
+			return err
+		}
+		event = balance
+		return recordEvents(ctx, s.outboxRepo, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publishEvents(s.events, event)
+	s.logger.InfoContext(ctx, "Balance adjusted", "target_user_id", userID, "amount", amount, "reason", reason)
+
+	return adjustment, nil
+}
+
+// SetBlocked blocks or unblocks a user account
+func (s *AdminService) SetBlocked(ctx context.Context, userID int64, blocked bool) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.SetBlocked")
+	defer span.End()
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Blocked = blocked
+	if err := s.userRepo.UpdateBlocked(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "User block changed", "target_user_id", userID, "blocked", blocked)
+
+	return user, nil
+}
+
+// getUser retrieves a user, translating a missing row into ErrUserNotFound
+func (s *AdminService) getUser(ctx context.Context, userID int64) (*entity.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
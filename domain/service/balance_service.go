@@ -2,10 +2,11 @@ package service
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"gophermart/domain/entity"
 	"gophermart/domain/repository"
+	"log/slog"
+	"strconv"
 )
 
 // BalanceService handles balance-related business logic
@@ -13,6 +14,11 @@ type BalanceService struct {
 	balanceRepo    repository.BalanceRepository
 	withdrawalRepo repository.WithdrawalRepository
 	orderRepo      repository.OrderRepository
+	outboxRepo     repository.OutboxRepository
+	transactor     repository.Transactor
+	events         *EventBus
+	metrics        MetricsRecorder
+	logger         *slog.Logger
 }
 
 // NewBalanceService creates a new BalanceService
@@ -20,24 +26,40 @@ func NewBalanceService(
 	balanceRepo repository.BalanceRepository,
 	withdrawalRepo repository.WithdrawalRepository,
 	orderRepo repository.OrderRepository,
+	outboxRepo repository.OutboxRepository,
+	transactor repository.Transactor,
+	events *EventBus,
+	metrics MetricsRecorder,
+	logger *slog.Logger,
 ) *BalanceService {
 	return &BalanceService{
 		balanceRepo:    balanceRepo,
 		withdrawalRepo: withdrawalRepo,
 		orderRepo:      orderRepo,
+		outboxRepo:     outboxRepo,
+		transactor:     transactor,
+		events:         events,
+		metrics:        metrics,
+		logger:         logger,
 	}
 }
 
 // GetUserBalance retrieves a user's balance
 func (s *BalanceService) GetUserBalance(ctx context.Context, userID int64) (*entity.Balance, error) {
+	ctx, span := tracer.Start(ctx, "BalanceService.GetUserBalance")
+	defer span.End()
+
 	return s.balanceRepo.GetOrCreate(ctx, userID)
 }
 
 // WithdrawPoints withdraws points from a user's balance
 func (s *BalanceService) WithdrawPoints(ctx context.Context, userID int64, orderID string, amount float64) error {
+	ctx, span := tracer.Start(ctx, "BalanceService.WithdrawPoints")
+	defer span.End()
+
 	// Validate order number
 	if !ValidateLuhn(orderID) {
-		return errors.New("invalid order number")
+		return ErrInvalidOrderNumber
 	}
 
 	// Check if order already exists
@@ -47,29 +69,90 @@ func (s *BalanceService) WithdrawPoints(ctx context.Context, userID int64, order
 	}
 
 	if exists {
-		return errors.New("order already exists")
+		return ErrOrderExists
+	}
+
+	events := []Event{{Type: EventWithdrawal, UserID: userID, Data: WithdrawalEvent{Order: orderID, Sum: amount}}}
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Update balance
+		if err := s.balanceRepo.UpdateBalance(ctx, userID, amount, true); err != nil {
+			return fmt.Errorf("failed to withdraw points: %w", err)
+		}
+
+		// Create withdrawal record
+		withdrawal := &entity.Withdrawal{
+			UserID:  userID,
+			OrderID: orderID,
+			Sum:     amount,
+		}
+
+		if err := s.withdrawalRepo.Create(ctx, withdrawal); err != nil {
+			return fmt.Errorf("failed to create withdrawal record: %w", err)
+		}
+
+		event, err := balanceEvent(ctx, s.balanceRepo, userID)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+
+		return recordEvents(ctx, s.outboxRepo, events...)
+	})
+	if err != nil {
+		return err
+	}
+
+	publishEvents(s.events, events...)
+	s.metrics.AddPointsWithdrawn(amount)
+	s.logger.InfoContext(ctx, "Points withdrawn", "order_id", orderID, "sum", amount)
+
+	return nil
+}
+
+// ListUserWithdrawals retrieves a page of a user's withdrawals
+func (s *BalanceService) ListUserWithdrawals(ctx context.Context, userID int64, params ListParams) (*WithdrawalPage, error) {
+	ctx, span := tracer.Start(ctx, "BalanceService.ListUserWithdrawals")
+	defer span.End()
+
+	params.Statuses = nil
+	params, after, err := normalizeListParams(params)
+	if err != nil {
+		return nil, err
 	}
 
-	// Update balance
-	if err := s.balanceRepo.UpdateBalance(ctx, userID, amount, true); err != nil {
-		return fmt.Errorf("failed to withdraw points: %w", err)
+	if after != nil {
+		if _, err := strconv.ParseInt(after.ID, 10, 64); err != nil {
+			return nil, ErrInvalidCursor
+		}
 	}
 
-	// Create withdrawal record
-	withdrawal := &entity.Withdrawal{
-		UserID:  userID,
-		OrderID: orderID,
-		Sum:     amount,
+	// Fetch one extra row to know whether there is a next page
+	withdrawals, err := s.withdrawalRepo.List(ctx, repository.WithdrawalFilter{
+		UserID:    userID,
+		From:      params.From,
+		To:        params.To,
+		Ascending: params.Ascending,
+		After:     after,
+		Limit:     params.Limit + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list withdrawals: %w", err)
 	}
 
-	if err := s.withdrawalRepo.Create(ctx, withdrawal); err != nil {
-		return fmt.Errorf("failed to create withdrawal record: %w", err)
+	page := &WithdrawalPage{Withdrawals: withdrawals}
+	if len(withdrawals) > params.Limit {
+		page.Withdrawals = withdrawals[:params.Limit]
+		last := page.Withdrawals[len(page.Withdrawals)-1]
+		page.NextCursor = encodeCursor(last.ProcessedAt, strconv.FormatInt(last.ID, 10), params.Ascending)
 	}
 
-	return nil
+	return page, nil
 }
 
 // GetUserWithdrawals retrieves all withdrawals for a user
 func (s *BalanceService) GetUserWithdrawals(ctx context.Context, userID int64) ([]entity.Withdrawal, error) {
+	ctx, span := tracer.Start(ctx, "BalanceService.GetUserWithdrawals")
+	defer span.End()
+
 	return s.withdrawalRepo.GetByUserID(ctx, userID)
 }
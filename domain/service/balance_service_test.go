@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gophermart/domain/entity"
+	"gophermart/internal/memory"
+	"testing"
+)
+
+func TestWithdrawPointsWithMemoryRepositories(t *testing.T) {
+	ctx := context.Background()
+
+	users, orders, balances := memory.NewUserRepo(), memory.NewOrderRepo(), memory.NewBalanceRepo()
+	withdrawals, outbox := memory.NewWithdrawalRepo(), memory.NewOutboxRepo()
+	transactor := memory.NewTransactor(users, orders, balances, withdrawals, outbox)
+	events := NewEventBus(DefaultEventHistory)
+	metrics := &metricsStub{}
+
+	orderService := NewOrderService(orders, balances, outbox, transactor, events, metrics, discardLogger())
+	balanceService := NewBalanceService(balances, withdrawals, orders, outbox, transactor, events, metrics, discardLogger())
+
+	user := &entity.User{Login: "alice", Password: "hash", Role: entity.RoleUser}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := orderService.UploadOrder(ctx, "79927398713", user.ID); err != nil {
+		t.Fatalf("UploadOrder failed: %v", err)
+	}
+	if err := orderService.UpdateOrderStatus(ctx, "79927398713", entity.StatusProcessed, 100); err != nil {
+		t.Fatalf("UpdateOrderStatus failed: %v", err)
+	}
+
+	if err := balanceService.WithdrawPoints(ctx, user.ID, "12345678903", 40); err != nil {
+		t.Fatalf("WithdrawPoints failed: %v", err)
+	}
+
+	// The failed withdrawal leaves no record behind
+	if err := balanceService.WithdrawPoints(ctx, user.ID, "0", 100); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("WithdrawPoints over the balance error = %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	balance, err := balanceService.GetUserBalance(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserBalance failed: %v", err)
+	}
+	if balance.Current != 60 || balance.Withdrawn != 40 {
+		t.Errorf("balance is %v current, %v withdrawn, want 60 and 40", balance.Current, balance.Withdrawn)
+	}
+
+	history, err := balanceService.GetUserWithdrawals(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserWithdrawals failed: %v", err)
+	}
+	if len(history) != 1 || history[0].OrderID != "12345678903" {
+		t.Errorf("withdrawals = %+v, want the successful one", history)
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"gophermart/internal/sqlite"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+// discardLogger returns a logger that drops all records
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newTestDB opens a migrated SQLite database that is removed when the test ends
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "gophermart.db"), nil, discardLogger())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := sqlite.NewMigrator(db, discardLogger())
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"gophermart/domain/repository"
+)
+
+// Errors returned by services, match them with errors.Is
+var (
+	// Users and sessions
+	ErrUserExists          = errors.New("user already exists")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrAccountBlocked      = errors.New("account blocked")
+	ErrSessionRevoked      = errors.New("session revoked")
+	ErrInvalidRole         = errors.New("invalid role")
+	ErrInvalidResetToken   = errors.New("invalid reset token")
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication not enrolled")
+	ErrInvalidTwoFactor    = errors.New("invalid two-factor code")
+	ErrTwoFactorLocked     = errors.New("too many failed two-factor attempts")
+
+	// External identities
+	ErrInvalidExternalProfile = errors.New("invalid external profile")
+	ErrIdentityLinked         = errors.New("external identity linked to another user")
+
+	// Orders
+	ErrInvalidOrderNumber   = errors.New("invalid order number")
+	ErrOrderNotFound        = errors.New("order not found")
+	ErrOrderOwnedByOther    = errors.New("order already uploaded by another user")
+	ErrOrderAlreadyUploaded = errors.New("order already uploaded by you")
+	ErrOrderExists          = errors.New("order already exists")
+	ErrAccrualUnavailable   = errors.New("accrual system unavailable")
+	ErrEmptyBatch           = errors.New("batch contains no orders")
+	ErrBatchTooLarge        = errors.New("batch contains too many orders")
+
+	// Balance
+	ErrInsufficientFunds = repository.ErrInsufficientFunds
+
+	// Listings
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidListParams = errors.New("invalid list parameters")
+
+	// Idempotency
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key reused with a different request")
+
+	// Back office
+	ErrSearchQueryRequired = errors.New("search query is required")
+	ErrReasonRequired      = errors.New("reason is required")
+	ErrZeroAmount          = errors.New("amount must not be zero")
+
+	// Webhooks
+	ErrInvalidWebhookURL     = errors.New("webhook URL must be an absolute http or https URL")
+	ErrWebhookURLNotAllowed  = errors.New("webhook URL must resolve to a public address")
+	ErrInvalidEventType      = errors.New("unknown event type")
+	ErrWebhookSecretRequired = errors.New("webhook secret is required")
+	ErrWebhookNotFound       = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound      = errors.New("webhook delivery not found")
+	ErrDeliveryNotReplayable = errors.New("only dead webhook deliveries can be replayed")
+)
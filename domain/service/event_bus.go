@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"sync"
+	"time"
+)
+
+// Event types published on the event bus
+const (
+	EventOrderStatus = "order.status"
+	EventBalance     = "balance.updated"
+	EventWithdrawal  = "withdrawal.created"
+)
+
+// EventTypes lists all event types published on the event bus
+var EventTypes = []string{EventOrderStatus, EventBalance, EventWithdrawal}
+
+// DefaultEventHistory is the number of recent events kept for resuming subscribers
+const DefaultEventHistory = 1024
+
+// subscriberBuffer is the number of events buffered per subscriber before it is dropped
+const subscriberBuffer = 64
+
+// Event is a change that concerns a single user
+type Event struct {
+	ID        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	UserID    int64     `json:"-"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrderStatusEvent is the payload of an order status event
+type OrderStatusEvent struct {
+	Order   string  `json:"order"`
+	Status  string  `json:"status"`
+	Accrual float64 `json:"accrual,omitempty"`
+}
+
+// BalanceEvent is the payload of a balance event
+type BalanceEvent struct {
+	Current   float64 `json:"current"`
+	Withdrawn float64 `json:"withdrawn"`
+}
+
+// WithdrawalEvent is the payload of a withdrawal event
+type WithdrawalEvent struct {
+	Order string  `json:"order"`
+	Sum   float64 `json:"sum"`
+}
+
+// Subscription receives the events of a single user
+type Subscription struct {
+	// Events is closed when the subscription is cancelled or the subscriber falls behind
+	Events <-chan Event
+
+	bus    *EventBus
+	userID int64
+	ch     chan Event
+}
+
+// Cancel stops delivery of events to the subscription
+func (s *Subscription) Cancel() {
+	s.bus.unsubscribe(s)
+}
+
+// EventBus is an in-process publish/subscribe hub for per-user events.
+// It keeps a bounded history so that subscribers can resume after reconnecting.
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	subscribers map[int64]map[*Subscription]struct{}
+}
+
+// NewEventBus creates a new EventBus keeping the given number of recent events
+func NewEventBus(historySize int) *EventBus {
+	if historySize <= 0 {
+		historySize = DefaultEventHistory
+	}
+
+	return &EventBus{
+		historySize: historySize,
+		subscribers: make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+// Publish delivers an event to all subscribers of the user
+func (b *EventBus) Publish(userID int64, eventType string, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{
+		ID:        b.nextID,
+		Type:      eventType,
+		UserID:    userID,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers[userID] {
+		select {
+		case sub.ch <- event:
+		default:
+			// A subscriber that cannot keep up is dropped, it can resume from its last event
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the user's events.
+// Events published after lastEventID that are still in the history are returned for replay;
+// a zero lastEventID replays nothing.
+func (b *EventBus) Subscribe(userID int64, lastEventID uint64) ([]Event, *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	// An ID from the future was issued before a restart and cannot be resumed
+	if lastEventID > 0 && lastEventID <= b.nextID {
+		for _, event := range b.history {
+			if event.ID > lastEventID && event.UserID == userID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{Events: ch, bus: b, userID: userID, ch: ch}
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	return replay, sub
+}
+
+// unsubscribe removes a subscription if it is still registered
+func (b *EventBus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removeLocked(sub)
+}
+
+// removeLocked removes a subscription and closes its channel, the caller must hold the lock
+func (b *EventBus) removeLocked(sub *Subscription) {
+	subs := b.subscribers[sub.userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.userID)
+	}
+	close(sub.ch)
+}
+
+// orderStatusEvent describes the current status of an order
+func orderStatusEvent(order *entity.Order) Event {
+	return Event{
+		Type:   EventOrderStatus,
+		UserID: order.UserID,
+		Data: OrderStatusEvent{
+			Order:   order.ID,
+			Status:  order.Status,
+			Accrual: order.Accrual,
+		},
+	}
+}
+
+// balanceEvent describes the current balance of a user, read within the transaction of ctx if there is one
+func balanceEvent(ctx context.Context, balanceRepo repository.BalanceRepository, userID int64) (Event, error) {
+	balance, err := balanceRepo.GetOrCreate(ctx, userID)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	return Event{
+		Type:   EventBalance,
+		UserID: userID,
+		Data: BalanceEvent{
+			Current:   balance.Current,
+			Withdrawn: balance.Withdrawn,
+		},
+	}, nil
+}
+
+// publishEvents publishes events on the bus once the changes they describe are committed
+func publishEvents(bus *EventBus, events ...Event) {
+	for _, event := range events {
+		bus.Publish(event.UserID, event.Type, event.Data)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// IdempotencyService stores responses to requests made with an Idempotency-Key so that retries replay them
+type IdempotencyService struct {
+	repo            repository.IdempotencyRepository
+	ttl             time.Duration
+	cleanupInterval time.Duration
+	logger          *slog.Logger
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
+}
+
+// NewIdempotencyService creates a new IdempotencyService
+func NewIdempotencyService(
+	repo repository.IdempotencyRepository,
+	ttl, cleanupInterval time.Duration,
+	logger *slog.Logger,
+) *IdempotencyService {
+	return &IdempotencyService{
+		repo:            repo,
+		ttl:             ttl,
+		cleanupInterval: cleanupInterval,
+		logger:          logger,
+		stopCh:          make(chan struct{}),
+	}
+}
+
+// Begin reserves a key for a request.
+// It returns nil when the caller should process the request and then call Complete or Release,
+// or the stored record when the request has already been processed and must be replayed.
+func (s *IdempotencyService) Begin(ctx context.Context, userID int64, key, requestHash string) (*entity.IdempotencyRecord, error) {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Begin")
+	defer span.End()
+
+	now := time.Now()
+	record := &entity.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+
+	created, err := s.repo.Create(ctx, record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if created {
+		return nil, nil
+	}
+
+	existing, err := s.repo.Get(ctx, userID, key)
+	if err != nil {
+		// The record expired and was purged between the two queries
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrIdempotencyInProgress
+		}
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+
+	// An expired key can be reused
+	if existing.ExpiresAt.Before(now) {
+		if err := s.repo.Delete(ctx, userID, key); err != nil {
+			return nil, fmt.Errorf("failed to delete expired idempotency record: %w", err)
+		}
+		return s.Begin(ctx, userID, key, requestHash)
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	if !existing.Completed {
+		return nil, ErrIdempotencyInProgress
+	}
+
+	return existing, nil
+}
+
+// Complete stores the response for a reserved key
+func (s *IdempotencyService) Complete(ctx context.Context, record *entity.IdempotencyRecord) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Complete")
+	defer span.End()
+
+	record.Completed = true
+	if err := s.repo.Complete(ctx, record); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees a reserved key without storing a response, so the request can be retried
+func (s *IdempotencyService) Release(ctx context.Context, userID int64, key string) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Release")
+	defer span.End()
+
+	if err := s.repo.Delete(ctx, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Start starts periodic removal of expired records
+func (s *IdempotencyService) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.cleanup(ctx)
+}
+
+// Stop stops periodic removal of expired records
+func (s *IdempotencyService) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// cleanup periodically deletes expired records
+func (s *IdempotencyService) cleanup(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired(ctx)
+		case <-s.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deleteExpired deletes the records that expired
+func (s *IdempotencyService) deleteExpired(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.deleteExpired")
+	defer span.End()
+
+	if _, err := s.repo.DeleteExpired(ctx); err != nil {
+		recordSpanError(span, err)
+		s.logger.ErrorContext(ctx, "Failed to delete expired idempotency records", "error", err)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"log/slog"
+)
+
+// ExternalProfile describes a user authenticated by an external identity provider
+type ExternalProfile struct {
+	Issuer  string
+	Subject string
+	Login   string // Preferred login, used when provisioning a new user
+}
+
+// IdentityService handles login through external identity providers
+type IdentityService struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.ExternalIdentityRepository
+	logger       *slog.Logger
+}
+
+// NewIdentityService creates a new IdentityService
+func NewIdentityService(
+	userRepo repository.UserRepository,
+	identityRepo repository.ExternalIdentityRepository,
+	logger *slog.Logger,
+) *IdentityService {
+	return &IdentityService{
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		logger:       logger,
+	}
+}
+
+// Login resolves an external profile to a user.
+// A known identity logs in its linked user. An unknown identity is linked to
+// linkUserID when it is non-zero, otherwise a new user is provisioned for it.
+func (s *IdentityService) Login(ctx context.Context, profile ExternalProfile, linkUserID int64) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "IdentityService.Login")
+	defer span.End()
+
+	if profile.Issuer == "" || profile.Subject == "" {
+		return nil, ErrInvalidExternalProfile
+	}
+
+	identity, err := s.identityRepo.GetByIssuerSubject(ctx, profile.Issuer, profile.Subject)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get external identity: %w", err)
+	}
+
+	if err == nil {
+		if linkUserID != 0 && identity.UserID != linkUserID {
+			return nil, ErrIdentityLinked
+		}
+
+		user, err := s.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if user.Blocked {
+			return nil, ErrAccountBlocked
+		}
+
+		return user, nil
+	}
+
+	var user *entity.User
+	if linkUserID != 0 {
+		user, err = s.userRepo.GetByID(ctx, linkUserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+	} else {
+		user, err = s.provisionUser(ctx, profile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	identity = &entity.ExternalIdentity{
+		UserID:  user.ID,
+		Issuer:  profile.Issuer,
+		Subject: profile.Subject,
+	}
+
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to link external identity: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "External identity linked", "user_id", user.ID, "issuer", profile.Issuer)
+
+	return user, nil
+}
+
+// provisionUser creates a user without a usable password for an external profile.
+// Existing users are never matched by login, since that would let the identity
+// provider take over local accounts.
+func (s *IdentityService) provisionUser(ctx context.Context, profile ExternalProfile) (*entity.User, error) {
+	sum := sha256.Sum256([]byte(profile.Issuer + "\x00" + profile.Subject))
+	suffix := hex.EncodeToString(sum[:4])
+
+	login := profile.Login
+	if login == "" {
+		login = "sso-" + suffix
+	} else if existing, err := s.userRepo.GetByLogin(ctx, login); err == nil && existing != nil {
+		login = login + "-" + suffix
+	}
+
+	// An empty hash never matches in bcrypt, so password login stays disabled
+	user := &entity.User{Login: login, Role: entity.RoleUser}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import "time"
+
+// Outcomes of calls to the accrual system
+const (
+	AccrualOutcomeOK            = "ok"
+	AccrualOutcomeNotRegistered = "not_registered"
+	AccrualOutcomeRateLimited   = "rate_limited"
+	AccrualOutcomeError         = "error"
+)
+
+// MetricsRecorder receives measurements of the services
+type MetricsRecorder interface {
+	// ObserveAccrualCall records a call to the accrual system
+	ObserveAccrualCall(outcome string, duration time.Duration)
+	// ObservePollCycle records a poll of the accrual system and the number of orders it found pending
+	ObservePollCycle(duration time.Duration, pendingOrders int)
+	// AddPointsAccrued counts points credited for processed orders
+	AddPointsAccrued(points float64)
+	// AddPointsWithdrawn counts points withdrawn by users
+	AddPointsWithdrawn(points float64)
+}
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"context"
+	"gophermart/domain/entity"
+)
+
+// Notifier delivers out-of-band messages to users
+type Notifier interface {
+	// NotifyPasswordReset delivers a password reset token to the user
+	NotifyPasswordReset(ctx context.Context, user *entity.User, token string) error
+}
@@ -6,28 +6,67 @@ import (
 	"fmt"
 	"gophermart/domain/entity"
 	"gophermart/domain/repository"
+	"log/slog"
 	"strconv"
 )
 
+// MaxBatchOrders is the maximum number of orders accepted in a single batch upload
+const MaxBatchOrders = 1000
+
+// Per-order outcomes of a batch upload
+const (
+	UploadAccepted     = "accepted"
+	UploadDuplicateOwn = "duplicate-own"
+	UploadConflict     = "conflict"
+	UploadInvalid      = "invalid"
+)
+
+// OrderUploadResult is the outcome of uploading one order of a batch
+type OrderUploadResult struct {
+	Order  string `json:"order"`
+	Status string `json:"status"`
+}
+
 // OrderService handles order-related business logic
 type OrderService struct {
 	orderRepo   repository.OrderRepository
 	balanceRepo repository.BalanceRepository
+	outboxRepo  repository.OutboxRepository
+	transactor  repository.Transactor
+	events      *EventBus
+	metrics     MetricsRecorder
+	logger      *slog.Logger
 }
 
 // NewOrderService creates a new OrderService
-func NewOrderService(orderRepo repository.OrderRepository, balanceRepo repository.BalanceRepository) *OrderService {
+func NewOrderService(
+	orderRepo repository.OrderRepository,
+	balanceRepo repository.BalanceRepository,
+	outboxRepo repository.OutboxRepository,
+	transactor repository.Transactor,
+	events *EventBus,
+	metrics MetricsRecorder,
+	logger *slog.Logger,
+) *OrderService {
 	return &OrderService{
 		orderRepo:   orderRepo,
 		balanceRepo: balanceRepo,
+		outboxRepo:  outboxRepo,
+		transactor:  transactor,
+		events:      events,
+		metrics:     metrics,
+		logger:      logger,
 	}
 }
 
 // UploadOrder uploads a new order
 func (s *OrderService) UploadOrder(ctx context.Context, orderID string, userID int64) (*entity.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.UploadOrder")
+	defer span.End()
+
 	// Validate order number using Luhn algorithm
 	if !ValidateLuhn(orderID) {
-		return nil, errors.New("invalid order number")
+		return nil, ErrInvalidOrderNumber
 	}
 
 	// Check if order already exists
@@ -38,12 +77,12 @@ func (s *OrderService) UploadOrder(ctx context.Context, orderID string, userID i
 
 	// If order exists and belongs to another user
 	if exists && existingUserID != userID {
-		return nil, errors.New("order already uploaded by another user")
+		return nil, ErrOrderOwnedByOther
 	}
 
 	// If order exists and belongs to the current user
 	if exists && existingUserID == userID {
-		return nil, errors.New("order already uploaded by you")
+		return nil, ErrOrderAlreadyUploaded
 	}
 
 	// Create new order
@@ -53,49 +92,212 @@ func (s *OrderService) UploadOrder(ctx context.Context, orderID string, userID i
 		Status: entity.StatusNew,
 	}
 
-	if err := s.orderRepo.Create(ctx, order); err != nil {
-		return nil, fmt.Errorf("failed to create order: %w", err)
+	event := orderStatusEvent(order)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orderRepo.Create(ctx, order); err != nil {
+			return fmt.Errorf("failed to create order: %w", err)
+		}
+		return recordEvents(ctx, s.outboxRepo, event)
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	publishEvents(s.events, event)
+	s.logger.InfoContext(ctx, "Order uploaded", "order_id", orderID)
+
 	return order, nil
 }
 
+// UploadOrders uploads a batch of orders in a single round-trip and reports the outcome for each of them.
+// Results are returned in the order of the input; repeated numbers within the batch are reported as duplicates.
+func (s *OrderService) UploadOrders(ctx context.Context, orderIDs []string, userID int64) ([]OrderUploadResult, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.UploadOrders")
+	defer span.End()
+
+	if len(orderIDs) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	if len(orderIDs) > MaxBatchOrders {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]OrderUploadResult, len(orderIDs))
+	seen := make(map[string]bool, len(orderIDs))
+	toInsert := make([]string, 0, len(orderIDs))
+	for i, orderID := range orderIDs {
+		results[i].Order = orderID
+		switch {
+		case !ValidateLuhn(orderID):
+			results[i].Status = UploadInvalid
+		case seen[orderID]:
+			results[i].Status = UploadDuplicateOwn
+		default:
+			seen[orderID] = true
+			toInsert = append(toInsert, orderID)
+		}
+	}
+
+	if len(toInsert) == 0 {
+		return results, nil
+	}
+
+	outcomes := make(map[string]string, len(toInsert))
+	var events []Event
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		inserted, err := s.orderRepo.CreateBatch(ctx, userID, toInsert)
+		if err != nil {
+			return fmt.Errorf("failed to create orders: %w", err)
+		}
+
+		for _, r := range inserted {
+			switch {
+			case r.Inserted:
+				outcomes[r.ID] = UploadAccepted
+				events = append(events, orderStatusEvent(&entity.Order{ID: r.ID, UserID: userID, Status: entity.StatusNew}))
+			case r.OwnerID == userID:
+				outcomes[r.ID] = UploadDuplicateOwn
+			default:
+				outcomes[r.ID] = UploadConflict
+			}
+		}
+
+		return recordEvents(ctx, s.outboxRepo, events...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publishEvents(s.events, events...)
+	s.logger.InfoContext(ctx, "Order batch uploaded", "orders", len(orderIDs), "accepted", len(events))
+
+	// Only the first occurrence of a number carries the repository outcome
+	for i := range results {
+		if results[i].Status == "" {
+			results[i].Status = outcomes[results[i].Order]
+		}
+	}
+
+	return results, nil
+}
+
 // GetUserOrders retrieves all orders for a user
 func (s *OrderService) GetUserOrders(ctx context.Context, userID int64) ([]entity.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetUserOrders")
+	defer span.End()
+
 	return s.orderRepo.GetByUserID(ctx, userID)
 }
 
-// UpdateOrderStatus updates the status and accrual of an order
-func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID, status string, accrual float64) error {
-	order, err := s.orderRepo.GetByID(ctx, orderID)
+// ListUserOrders retrieves a page of a user's orders
+func (s *OrderService) ListUserOrders(ctx context.Context, userID int64, params ListParams) (*OrderPage, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.ListUserOrders")
+	defer span.End()
+
+	params, after, err := normalizeListParams(params)
 	if err != nil {
-		return fmt.Errorf("failed to get order: %w", err)
+		return nil, err
 	}
 
-	// Only update if status changed
-	if order.Status != status {
+	// Fetch one extra row to know whether there is a next page
+	orders, err := s.orderRepo.List(ctx, repository.OrderFilter{
+		UserID:    userID,
+		Statuses:  params.Statuses,
+		From:      params.From,
+		To:        params.To,
+		Ascending: params.Ascending,
+		After:     after,
+		Limit:     params.Limit + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+
+	page := &OrderPage{Orders: orders}
+	if len(orders) > params.Limit {
+		page.Orders = orders[:params.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = encodeCursor(last.UploadedAt, last.ID, params.Ascending)
+	}
+
+	return page, nil
+}
+
+// UpdateOrderStatus updates the status and accrual of an order
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID, status string, accrual float64) error {
+	ctx, span := tracer.Start(ctx, "OrderService.UpdateOrderStatus")
+	defer span.End()
+
+	var events []Event
+	var credited float64
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		order, err := s.orderRepo.GetByID(ctx, orderID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrOrderNotFound
+			}
+			return fmt.Errorf("failed to get order: %w", err)
+		}
+
+		// Only update if status changed
+		if order.Status == status {
+			return nil
+		}
+
+		from := order.Status
 		order.Status = status
 		order.Accrual = accrual
 
+		// Another poller or recheck may have moved the order since we read it,
+		// only the update that wins credits the accrual
+		updated, err := s.orderRepo.UpdateStatus(ctx, order, from)
+		if err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		if !updated {
+			return nil
+		}
+		events = append(events, orderStatusEvent(order))
+
 		// If order processed successfully, update user balance
 		if status == entity.StatusProcessed && accrual > 0 {
 			err := s.balanceRepo.UpdateBalance(ctx, order.UserID, accrual, false)
 			if err != nil {
 				return fmt.Errorf("failed to update balance: %w", err)
 			}
-		}
 
-		// Update order in the database
-		if err := s.orderRepo.Update(ctx, order); err != nil {
-			return fmt.Errorf("failed to update order: %w", err)
+			event, err := balanceEvent(ctx, s.balanceRepo, order.UserID)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+			credited = accrual
 		}
+
+		return recordEvents(ctx, s.outboxRepo, events...)
+	})
+	if err != nil {
+		return err
+	}
+
+	publishEvents(s.events, events...)
+	if len(events) > 0 {
+		s.logger.InfoContext(ctx, "Order status updated", "order_id", orderID, "status", status, "accrual", accrual)
+	}
+	if credited > 0 {
+		s.metrics.AddPointsAccrued(credited)
 	}
 
 	return nil
 }
 
-// ValidateLuhn validates a number using the Luhn algorithm
+// ValidateLuhn validates a number using the Luhn algorithm, an empty number is invalid
 func ValidateLuhn(number string) bool {
+	if number == "" {
+		return false
+	}
+
 	digits := make([]int, len(number))
 	for i, r := range number {
 		digit, err := strconv.Atoi(string(r))
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"gophermart/internal/memory"
+	"testing"
+)
+
+func TestValidateLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"79927398713", true},
+		{"12345678903", true},
+		{"0", true},
+		{"79927398710", false},
+		{"7992739871a", false},
+		{" 79927398713", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidateLuhn(tt.number); got != tt.want {
+			t.Errorf("ValidateLuhn(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+// staleOrderRepo reads every order as PROCESSING, like a poller or recheck that read
+// the order before a concurrent update committed
+type staleOrderRepo struct {
+	*memory.OrderRepo
+}
+
+func (r staleOrderRepo) GetByID(ctx context.Context, id string) (*entity.Order, error) {
+	order, err := r.OrderRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	order.Status = entity.StatusProcessing
+	return order, nil
+}
+
+func TestUpdateOrderStatusCreditsOnce(t *testing.T) {
+	ctx := context.Background()
+
+	users, orders, balances := memory.NewUserRepo(), memory.NewOrderRepo(), memory.NewBalanceRepo()
+	outbox := memory.NewOutboxRepo()
+	transactor := memory.NewTransactor(users, orders, balances, outbox)
+	metrics := &metricsStub{}
+
+	orderService := NewOrderService(staleOrderRepo{orders}, balances, outbox, transactor, NewEventBus(DefaultEventHistory), metrics, discardLogger())
+
+	user := &entity.User{Login: "alice", Password: "hash", Role: entity.RoleUser}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := orders.Create(ctx, &entity.Order{ID: "79927398713", UserID: user.ID, Status: entity.StatusProcessing}); err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+
+	// Both callers saw PROCESSING, only the first one moves the order to PROCESSED
+	for range 2 {
+		if err := orderService.UpdateOrderStatus(ctx, "79927398713", entity.StatusProcessed, 100); err != nil {
+			t.Fatalf("UpdateOrderStatus failed: %v", err)
+		}
+	}
+
+	balance, err := balances.GetOrCreate(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetOrCreate failed: %v", err)
+	}
+	if balance.Current != 100 || metrics.pointsAccrued != 100 {
+		t.Errorf("balance is %v and %v points accrued, want 100 credited once", balance.Current, metrics.pointsAccrued)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// outboxBatchSize is the number of events claimed per poll
+	outboxBatchSize = 100
+	// outboxLease is how long claimed events are hidden from other relays
+	outboxLease = time.Minute
+	// outboxPublishWindow is how long a relay starts publishing the events it claimed.
+	// Events left after it are released, so that a publish still in flight ends well within the lease.
+	outboxPublishWindow = outboxLease / 2
+	// outboxRetention is how long published events are kept
+	outboxRetention = 7 * 24 * time.Hour
+	// outboxCleanupInterval is the interval between removals of old published events
+	outboxCleanupInterval = time.Hour
+)
+
+// EventPublisher delivers domain events recorded in the outbox to downstream consumers.
+// Events are delivered at least once and in the order they were recorded,
+// so consumers should deduplicate them by ID.
+type EventPublisher interface {
+	Publish(ctx context.Context, event entity.OutboxEvent) error
+}
+
+// OutboxRelay publishes events recorded in the outbox
+type OutboxRelay struct {
+	repo         repository.OutboxRepository
+	publisher    EventPublisher
+	pollInterval time.Duration
+	logger       *slog.Logger
+	stopCh       chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewOutboxRelay creates a new OutboxRelay
+func NewOutboxRelay(
+	repo repository.OutboxRepository,
+	publisher EventPublisher,
+	pollInterval time.Duration,
+	logger *slog.Logger,
+) *OutboxRelay {
+	return &OutboxRelay{
+		repo:         repo,
+		publisher:    publisher,
+		pollInterval: pollInterval,
+		logger:       logger,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// Start starts relaying events
+func (r *OutboxRelay) Start(ctx context.Context) {
+	r.wg.Add(1)
+	go r.relay(ctx)
+}
+
+// Stop stops relaying events
+func (r *OutboxRelay) Stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
+
+// relay periodically publishes unpublished events and removes old published ones
+func (r *OutboxRelay) relay(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(outboxCleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := drainBatches(outboxBatchSize, func() (int, error) { return r.publishBatch(ctx) }); err != nil {
+				r.logger.ErrorContext(ctx, "Failed to publish outbox events", "error", err)
+			}
+		case <-cleanup.C:
+			if _, err := r.repo.DeletePublished(ctx, time.Now().Add(-outboxRetention)); err != nil {
+				r.logger.ErrorContext(ctx, "Failed to delete published outbox events", "error", err)
+			}
+		case <-r.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drainBatches runs process while it handles full batches, so a backlog drains without waiting for the next tick.
+// It stops at the first error.
+func drainBatches(batchSize int, process func() (int, error)) error {
+	for {
+		processed, err := process()
+		if err != nil {
+			return err
+		}
+		if processed < batchSize {
+			return nil
+		}
+	}
+}
+
+// publishBatch claims a batch of events, publishes them in order and reports how many were published.
+// No transaction is held while publishing, and a failure stops the batch so that later events wait for earlier ones.
+func (r *OutboxRelay) publishBatch(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRelay.publishBatch")
+	defer span.End()
+
+	claimedAt := time.Now()
+	events, err := r.repo.ClaimUnpublished(ctx, outboxBatchSize, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+
+	var published, unpublished []int64
+	var failure error
+	for _, event := range events {
+		// Events left after a failure or the publish window are released for the next poll
+		if failure != nil || time.Since(claimedAt) >= outboxPublishWindow {
+			unpublished = append(unpublished, event.ID)
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			failure = fmt.Errorf("failed to publish event %d: %w", event.ID, err)
+			if err := r.repo.RecordFailure(ctx, event.ID, err.Error()); err != nil {
+				r.logger.ErrorContext(ctx, "Failed to record outbox failure", "event_id", event.ID, "error", err)
+			}
+			unpublished = append(unpublished, event.ID)
+			continue
+		}
+		published = append(published, event.ID)
+	}
+
+	// Events published before a crash here are published again once their claim expires,
+	// which is what makes delivery at-least-once
+	if len(published) > 0 {
+		if err := r.repo.MarkPublished(ctx, published); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(unpublished) > 0 {
+		if err := r.repo.ReleaseClaims(ctx, unpublished); err != nil {
+			r.logger.ErrorContext(ctx, "Failed to release outbox event claims", "error", err)
+		}
+	}
+
+	return len(published), failure
+}
+
+// recordEvents writes events to the outbox, within the transaction of ctx if there is one
+func recordEvents(ctx context.Context, outbox repository.OutboxRepository, events ...Event) error {
+	records := make([]entity.OutboxEvent, len(events))
+	for i, event := range events {
+		payload, err := json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+		}
+
+		records[i] = entity.OutboxEvent{
+			Type:    event.Type,
+			UserID:  event.UserID,
+			Payload: payload,
+		}
+	}
+
+	if err := outbox.Add(ctx, records...); err != nil {
+		return fmt.Errorf("failed to record events: %w", err)
+	}
+
+	return nil
+}
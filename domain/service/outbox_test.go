@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gophermart/domain/entity"
+	"gophermart/internal/sqlite"
+	"slices"
+	"testing"
+)
+
+// publisherFunc adapts a function to EventPublisher
+type publisherFunc func(ctx context.Context, event entity.OutboxEvent) error
+
+// Publish calls the function
+func (f publisherFunc) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	return f(ctx, event)
+}
+
+// newOutboxFixture creates an outbox with events of a single user
+func newOutboxFixture(t *testing.T, count int) *sqlite.OutboxRepo {
+	t.Helper()
+
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	user := &entity.User{Login: "alice", Password: "hash", Role: entity.RoleUser}
+	if err := sqlite.NewUserRepo(db).Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	outbox := sqlite.NewOutboxRepo(db)
+	events := make([]Event, count)
+	for i := range events {
+		events[i] = Event{Type: EventBalance, UserID: user.ID, Data: map[string]int{"n": i}}
+	}
+	if err := recordEvents(ctx, outbox, events...); err != nil {
+		t.Fatalf("failed to record events: %v", err)
+	}
+
+	return outbox
+}
+
+func TestPublishBatchPublishesOutsideTransaction(t *testing.T) {
+	ctx := context.Background()
+	outbox := newOutboxFixture(t, 3)
+
+	var published []int64
+	relay := NewOutboxRelay(outbox, publisherFunc(func(_ context.Context, event entity.OutboxEvent) error {
+		// SQLite allows a single writer, a write of another connection fails while the relay holds a transaction
+		if err := outbox.Add(context.Background(), entity.OutboxEvent{Type: EventBalance, UserID: event.UserID, Payload: []byte("{}")}); err != nil {
+			return err
+		}
+		published = append(published, event.ID)
+		return nil
+	}), 0, discardLogger())
+
+	n, err := relay.publishBatch(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("publishBatch() = %d, %v, want 3 published", n, err)
+	}
+	if !slices.IsSorted(published) {
+		t.Errorf("events published in order %v, want the order they were recorded", published)
+	}
+
+	// The first batch is marked published, the events added while publishing are next
+	if n, err := relay.publishBatch(ctx); err != nil || n != 3 {
+		t.Fatalf("second publishBatch() = %d, %v, want the 3 events added while publishing", n, err)
+	}
+}
+
+func TestPublishBatchReleasesEventsAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	outbox := newOutboxFixture(t, 3)
+
+	fail := true
+	var published []int64
+	relay := NewOutboxRelay(outbox, publisherFunc(func(_ context.Context, event entity.OutboxEvent) error {
+		if fail && len(published) == 1 {
+			return errors.New("publisher unavailable")
+		}
+		published = append(published, event.ID)
+		return nil
+	}), 0, discardLogger())
+
+	if n, err := relay.publishBatch(ctx); err == nil || n != 1 {
+		t.Fatalf("publishBatch() = %d, %v, want 1 published and an error", n, err)
+	}
+
+	// The failed event and the ones after it are not left claimed until the lease expires
+	fail = false
+	if n, err := relay.publishBatch(ctx); err != nil || n != 2 {
+		t.Fatalf("retry publishBatch() = %d, %v, want the 2 remaining events", n, err)
+	}
+	if !slices.IsSorted(published) || len(published) != 3 {
+		t.Errorf("events published in order %v, want all 3 in the order they were recorded", published)
+	}
+}
+
+func TestClaimUnpublishedHidesClaimedEvents(t *testing.T) {
+	ctx := context.Background()
+	outbox := newOutboxFixture(t, 3)
+
+	claimed, err := outbox.ClaimUnpublished(ctx, 2, outboxLease)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("ClaimUnpublished() = %d events, %v, want 2", len(claimed), err)
+	}
+
+	others, err := outbox.ClaimUnpublished(ctx, 10, outboxLease)
+	if err != nil || len(others) != 1 || others[0].ID == claimed[0].ID || others[0].ID == claimed[1].ID {
+		t.Fatalf("second ClaimUnpublished() = %+v, %v, want only the unclaimed event", others, err)
+	}
+
+	if err := outbox.ReleaseClaims(ctx, []int64{claimed[1].ID}); err != nil {
+		t.Fatalf("ReleaseClaims() failed: %v", err)
+	}
+	released, err := outbox.ClaimUnpublished(ctx, 10, outboxLease)
+	if err != nil || len(released) != 1 || released[0].ID != claimed[1].ID {
+		t.Errorf("ClaimUnpublished() after release = %+v, %v, want the released event", released, err)
+	}
+}
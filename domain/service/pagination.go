@@ -0,0 +1,98 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"time"
+)
+
+// Page size limits for listings
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// ListParams describes a page of a listing requested by a client
+type ListParams struct {
+	Cursor    string    // opaque cursor returned with the previous page
+	Limit     int       // zero means DefaultPageLimit
+	Statuses  []string  // order statuses, ignored for withdrawals
+	From      time.Time // inclusive, zero means unbounded
+	To        time.Time // exclusive, zero means unbounded
+	Ascending bool
+}
+
+// OrderPage is a page of orders
+type OrderPage struct {
+	Orders     []entity.Order
+	NextCursor string // empty on the last page
+}
+
+// WithdrawalPage is a page of withdrawals
+type WithdrawalPage struct {
+	Withdrawals []entity.Withdrawal
+	NextCursor  string // empty on the last page
+}
+
+// cursorPayload is the decoded form of an opaque cursor
+type cursorPayload struct {
+	Time      time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Ascending bool      `json:"asc,omitempty"`
+}
+
+// encodeCursor builds an opaque cursor pointing after the given row
+func encodeCursor(t time.Time, id string, ascending bool) string {
+	b, _ := json.Marshal(cursorPayload{Time: t, ID: id, Ascending: ascending})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses an opaque cursor, which must have been issued for the same sort direction
+func decodeCursor(cursor string, ascending bool) (*repository.Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(b, &p); err != nil || p.ID == "" || p.Ascending != ascending {
+		return nil, ErrInvalidCursor
+	}
+
+	return &repository.Cursor{Time: p.Time, ID: p.ID}, nil
+}
+
+// normalizeListParams validates paging parameters and applies defaults
+func normalizeListParams(params ListParams) (ListParams, *repository.Cursor, error) {
+	switch {
+	case params.Limit < 0 || params.Limit > MaxPageLimit:
+		return params, nil, ErrInvalidListParams
+	case params.Limit == 0:
+		params.Limit = DefaultPageLimit
+	}
+
+	if !params.From.IsZero() && !params.To.IsZero() && !params.From.Before(params.To) {
+		return params, nil, ErrInvalidListParams
+	}
+
+	for _, status := range params.Statuses {
+		switch status {
+		case entity.StatusNew, entity.StatusProcessing, entity.StatusInvalid, entity.StatusProcessed:
+		default:
+			return params, nil, ErrInvalidListParams
+		}
+	}
+
+	after, err := decodeCursor(params.Cursor, params.Ascending)
+	if err != nil {
+		return params, nil, err
+	}
+
+	return params, after, nil
+}
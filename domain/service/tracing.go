@@ -0,0 +1,16 @@
+package service
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records a span for every service call, the spans of repository queries nest under them
+var tracer = otel.Tracer("gophermart/domain/service")
+
+// recordSpanError marks the span as failed
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
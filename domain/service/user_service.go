@@ -2,32 +2,72 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"gophermart/domain/entity"
 	"gophermart/domain/repository"
+	"log/slog"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// recoveryCodeCount is the number of recovery codes issued when enabling two-factor authentication
+const recoveryCodeCount = 10
+
 // UserService handles user-related business logic
 type UserService struct {
-	userRepo repository.UserRepository
+	userRepo     repository.UserRepository
+	resetRepo    repository.PasswordResetRepository
+	recoveryRepo repository.RecoveryCodeRepository
+	notifier     Notifier
+	policy       PasswordPolicy
+	resetTTL     time.Duration
+	logger       *slog.Logger
 }
 
 // NewUserService creates a new UserService
-func NewUserService(userRepo repository.UserRepository) *UserService {
+func NewUserService(
+	userRepo repository.UserRepository,
+	resetRepo repository.PasswordResetRepository,
+	recoveryRepo repository.RecoveryCodeRepository,
+	notifier Notifier,
+	policy PasswordPolicy,
+	resetTTL time.Duration,
+	logger *slog.Logger,
+) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:     userRepo,
+		resetRepo:    resetRepo,
+		recoveryRepo: recoveryRepo,
+		notifier:     notifier,
+		policy:       policy,
+		resetTTL:     resetTTL,
+		logger:       logger,
 	}
 }
 
 // Register registers a new user
 func (s *UserService) Register(ctx context.Context, login, password string) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Register")
+	defer span.End()
+
+	// Enforce the password policy
+	if err := s.policy.Validate(password); err != nil {
+		return nil, err
+	}
+
 	// Check if user already exists
-	existingUser, err := s.userRepo.GetByLogin(ctx, login)
-	if err == nil && existingUser != nil {
-		return nil, errors.New("user already exists")
+	_, err := s.userRepo.GetByLogin(ctx, login)
+	if err == nil {
+		return nil, ErrUserExists
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check user existence: %w", err)
 	}
 
 	// Hash the password
@@ -40,27 +80,424 @@ func (s *UserService) Register(ctx context.Context, login, password string) (*en
 	user := &entity.User{
 		Login:    login,
 		Password: string(hashedPassword),
+		Role:     entity.RoleUser,
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	s.logger.InfoContext(ctx, "User registered", "user_id", user.ID)
+
 	return user, nil
 }
 
-// Login authenticates a user
+// Login authenticates a user.
+// If the user has two-factor authentication enabled, the caller must complete
+// the second step with VerifySecondFactor before treating the user as logged in.
 func (s *UserService) Login(ctx context.Context, login, password string) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer span.End()
+
 	user, err := s.userRepo.GetByLogin(ctx, login)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	// Verify the password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		s.logger.WarnContext(ctx, "Login failed", "user_id", user.ID)
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.Blocked {
+		s.logger.WarnContext(ctx, "Login to blocked account", "user_id", user.ID)
+		return nil, ErrAccountBlocked
+	}
+
+	return user, nil
+}
+
+// ValidateSession checks that a token issued with the given version has not been revoked
+// and returns the current state of the user
+func (s *UserService) ValidateSession(ctx context.Context, userID int64, tokenVersion int) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ValidateSession")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.TokenVersion != tokenVersion {
+		return nil, ErrSessionRevoked
+	}
+
+	if user.Blocked {
+		return nil, ErrAccountBlocked
+	}
+
+	return user, nil
+}
+
+// SetRole changes the role of a user
+func (s *UserService) SetRole(ctx context.Context, userID int64, role string) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.SetRole")
+	defer span.End()
+
+	if !IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	user.Role = role
+	if err := s.userRepo.UpdateRole(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
 	}
 
+	s.logger.InfoContext(ctx, "User role changed", "target_user_id", userID, "role", role)
+
 	return user, nil
 }
+
+// SeedAdmin makes sure an admin with the given login exists.
+// An existing user is promoted, otherwise a new admin is registered with the password.
+func (s *UserService) SeedAdmin(ctx context.Context, login, password string) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.SeedAdmin")
+	defer span.End()
+
+	user, err := s.userRepo.GetByLogin(ctx, login)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		user, err = s.Register(ctx, login, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Role == entity.RoleAdmin {
+		return user, nil
+	}
+
+	return s.SetRole(ctx, user.ID, entity.RoleAdmin)
+}
+
+// IsValidRole reports whether role is one of the known user roles
+func IsValidRole(role string) bool {
+	switch role {
+	case entity.RoleUser, entity.RoleSupport, entity.RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// ChangePassword replaces the password of an authenticated user and revokes all issued tokens
+func (s *UserService) ChangePassword(ctx context.Context, userID int64, oldPassword, newPassword string) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Verify the old password
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "Password changed")
+
+	return user, nil
+}
+
+// RequestPasswordReset issues a reset token and delivers it through the notifier.
+// Unknown logins are silently ignored so that the endpoint does not reveal which accounts exist.
+func (s *UserService) RequestPasswordReset(ctx context.Context, login string) error {
+	ctx, span := tracer.Start(ctx, "UserService.RequestPasswordReset")
+	defer span.End()
+
+	user, err := s.userRepo.GetByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	reset := &entity.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		ExpiresAt: time.Now().Add(s.resetTTL),
+	}
+
+	if err := s.resetRepo.Create(ctx, reset); err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	if err := s.notifier.NotifyPasswordReset(ctx, user, token); err != nil {
+		return fmt.Errorf("failed to deliver password reset: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and revokes all issued tokens
+func (s *UserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "UserService.ResetPassword")
+	defer span.End()
+
+	// Validate before consuming the token so that a weak password does not burn it
+	if err := s.policy.Validate(newPassword); err != nil {
+		return err
+	}
+
+	reset, err := s.resetRepo.Consume(ctx, hashResetToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("failed to consume reset token: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, reset.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "Password reset", "user_id", user.ID)
+
+	return nil
+}
+
+// EnrollTOTP generates a new TOTP secret for the user and returns it with an otpauth URI.
+// The secret is not enforced until it is confirmed with ConfirmTOTP.
+func (s *UserService) EnrollTOTP(ctx context.Context, userID int64) (secret, uri string, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.EnrollTOTP")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.TOTPEnabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+
+	secret, err = generateTOTPSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+
+	user.TOTPSecret = secret
+	if err := s.userRepo.UpdateTOTP(ctx, user); err != nil {
+		return "", "", fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	return secret, totpURI(user.Login, secret), nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves possession
+// of the enrolled secret, and returns a fresh set of recovery codes
+func (s *UserService) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ConfirmTOTP")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactor
+	}
+
+	// The confirmation code must not be usable for a login afterwards
+	if _, err := s.userRepo.AcceptTOTP(ctx, user.ID, step); err != nil {
+		return nil, fmt.Errorf("failed to accept TOTP code: %w", err)
+	}
+
+	codes, err := s.regenerateRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	if err := s.userRepo.UpdateTOTP(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Two-factor authentication enabled")
+
+	return codes, nil
+}
+
+// DisableTOTP turns off two-factor authentication after checking a TOTP or recovery code
+func (s *UserService) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	ctx, span := tracer.Start(ctx, "UserService.DisableTOTP")
+	defer span.End()
+
+	user, err := s.VerifySecondFactor(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	if err := s.userRepo.UpdateTOTP(ctx, user); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	if err := s.recoveryRepo.Replace(ctx, user.ID, nil); err != nil {
+		return fmt.Errorf("failed to remove recovery codes: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Two-factor authentication disabled")
+
+	return nil
+}
+
+// VerifySecondFactor checks a TOTP code or consumes a recovery code for the user.
+// Users are locked out after too many failed attempts and every TOTP code is accepted only once.
+func (s *UserService) VerifySecondFactor(ctx context.Context, userID int64, code string) (*entity.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.VerifySecondFactor")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	// The attempt counts as failed until the code is accepted
+	allowed, err := s.userRepo.BeginTOTPAttempt(ctx, user.ID, totpMaxFailures, totpLockout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record two-factor attempt: %w", err)
+	}
+	if !allowed {
+		s.logger.WarnContext(ctx, "Two-factor attempt while locked out", "target_user_id", user.ID)
+		return nil, ErrTwoFactorLocked
+	}
+
+	step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		// Fall back to a single-use recovery code
+		if err := s.recoveryRepo.Consume(ctx, user.ID, hashRecoveryCode(code)); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrInvalidTwoFactor
+			}
+			return nil, fmt.Errorf("failed to consume recovery code: %w", err)
+		}
+	}
+
+	// The step stays 0 for a recovery code, which only clears the failures
+	accepted, err := s.userRepo.AcceptTOTP(ctx, user.ID, step)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept two-factor code: %w", err)
+	}
+	if !accepted {
+		// The code was already used, possibly by someone who observed it
+		return nil, ErrInvalidTwoFactor
+	}
+
+	return user, nil
+}
+
+// regenerateRecoveryCodes replaces the user's recovery codes with new ones
+func (s *UserService) regenerateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := hex.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := s.recoveryRepo.Replace(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// setPassword validates, hashes and stores a new password for the user
+func (s *UserService) setPassword(ctx context.Context, user *entity.User, password string) error {
+	if err := s.policy.Validate(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user.Password = string(hashedPassword)
+	if err := s.userRepo.UpdatePassword(ctx, user); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+// generateResetToken generates a random URL-safe reset token
+func generateResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashRecoveryCode returns the hex-encoded SHA-256 of a normalized recovery code
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashResetToken returns the hex-encoded SHA-256 of a reset token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// nonPublicPrefixes are ranges that are not flagged by the netip predicates but must not be reached either
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, embeds IPv4 addresses
+	netip.MustParsePrefix("2002::/16"),     // 6to4, embeds IPv4 addresses
+}
+
+// isPublicAddress reports whether webhooks may be sent to an address.
+// Loopback, private, link-local (including the cloud metadata endpoint 169.254.169.254),
+// multicast and unspecified addresses are rejected.
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkWebhookHost resolves the host of a subscription URL and checks that all its addresses are public
+func checkWebhookHost(ctx context.Context, resolver *net.Resolver, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !isPublicAddress(addr) {
+			return ErrWebhookURLNotAllowed
+		}
+		return nil
+	}
+
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return ErrWebhookURLNotAllowed
+	}
+
+	for _, addr := range addrs {
+		if !isPublicAddress(addr) {
+			return ErrWebhookURLNotAllowed
+		}
+	}
+
+	return nil
+}
+
+// dialPublicOnly is a net.Dialer Control function that refuses connections to non-public addresses.
+// It runs after name resolution, so a host that resolves to another address after the subscription
+// was created is still refused.
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %q: %w", address, err)
+	}
+
+	if !isPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s is not public: %w", addrPort.Addr(), ErrWebhookURLNotAllowed)
+	}
+
+	return nil
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries the timestamp and HMAC-SHA256 signature of a webhook payload
+	WebhookSignatureHeader = "X-Gophermart-Signature"
+	// WebhookEventHeader carries the event type of a webhook payload
+	WebhookEventHeader = "X-Gophermart-Event"
+	// WebhookDeliveryHeader carries the delivery ID, which stays the same across retries
+	WebhookDeliveryHeader = "X-Gophermart-Delivery"
+
+	// webhookWorkers is the number of deliveries sent concurrently
+	webhookWorkers = 10
+	// webhookTimeout bounds a single delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookBatchSize is the number of deliveries claimed per poll.
+	// A batch is sent within webhookBatchSize / webhookWorkers * webhookTimeout, well within the lease.
+	webhookBatchSize = 4 * webhookWorkers
+	// webhookLease is how long a claimed delivery is hidden from other workers
+	webhookLease = time.Minute
+	// webhookFanOutBatchSize is the number of outbox events queued for webhooks per transaction
+	webhookFanOutBatchSize = 100
+	// maxWebhookBackoff caps the delay between retries
+	maxWebhookBackoff = 6 * time.Hour
+	// maxDeliveryHistory is the number of deliveries returned per subscription
+	maxDeliveryHistory = 100
+	// maxWebhookErrorLength caps the stored error of a failed attempt
+	maxWebhookErrorLength = 500
+)
+
+// WebhookService manages webhook subscriptions and delivers events to them through a durable queue
+type WebhookService struct {
+	repo         repository.WebhookRepository
+	outboxRepo   repository.OutboxRepository
+	transactor   repository.Transactor
+	client       *http.Client
+	maxAttempts  int
+	baseBackoff  time.Duration
+	pollInterval time.Duration
+	allowPrivate bool
+	logger       *slog.Logger
+	stopCh       chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewWebhookService creates a new WebhookService.
+// Unless allowPrivate is set, subscriptions and deliveries to loopback, private and link-local addresses are refused.
+func NewWebhookService(
+	repo repository.WebhookRepository,
+	outboxRepo repository.OutboxRepository,
+	transactor repository.Transactor,
+	maxAttempts int,
+	baseBackoff time.Duration,
+	pollInterval time.Duration,
+	allowPrivate bool,
+	logger *slog.Logger,
+) *WebhookService {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = dialPublicOnly
+	}
+
+	return &WebhookService{
+		repo:       repo,
+		outboxRepo: outboxRepo,
+		transactor: transactor,
+		client: &http.Client{
+			Timeout: webhookTimeout,
+			// No proxy, the dialer has to see the address of the subscriber
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				MaxIdleConnsPerHost: webhookWorkers,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: webhookTimeout,
+			},
+		},
+		maxAttempts:  maxAttempts,
+		baseBackoff:  baseBackoff,
+		pollInterval: pollInterval,
+		allowPrivate: allowPrivate,
+		logger:       logger,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// CreateSubscription registers a URL to receive the given event types signed with the secret
+func (s *WebhookService) CreateSubscription(
+	ctx context.Context,
+	userID int64,
+	rawURL string,
+	eventTypes []string,
+	secret string,
+) (*entity.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.CreateSubscription")
+	defer span.End()
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	if !s.allowPrivate {
+		if err := checkWebhookHost(ctx, net.DefaultResolver, u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(eventTypes) == 0 {
+		return nil, ErrInvalidEventType
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(EventTypes, eventType) {
+			return nil, ErrInvalidEventType
+		}
+	}
+
+	if strings.TrimSpace(secret) == "" {
+		return nil, ErrWebhookSecretRequired
+	}
+
+	subscription := &entity.WebhookSubscription{
+		UserID:     userID,
+		URL:        u.String(),
+		EventTypes: slices.Compact(slices.Sorted(slices.Values(eventTypes))),
+		Secret:     secret,
+	}
+
+	if err := s.repo.CreateSubscription(ctx, subscription); err != nil {
+		return nil, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+
+	return subscription, nil
+}
+
+// GetUserSubscriptions retrieves all webhook subscriptions of a user
+func (s *WebhookService) GetUserSubscriptions(ctx context.Context, userID int64) ([]entity.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetUserSubscriptions")
+	defer span.End()
+
+	return s.repo.GetSubscriptionsByUserID(ctx, userID)
+}
+
+// DeleteSubscription deletes a subscription of the user together with its deliveries
+func (s *WebhookService) DeleteSubscription(ctx context.Context, userID, subscriptionID int64) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DeleteSubscription")
+	defer span.End()
+
+	if _, err := s.getSubscription(ctx, userID, subscriptionID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteSubscription(ctx, subscriptionID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrWebhookNotFound
+		}
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliveries retrieves the most recent deliveries of a subscription of the user
+func (s *WebhookService) GetDeliveries(ctx context.Context, userID, subscriptionID int64) ([]entity.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetDeliveries")
+	defer span.End()
+
+	if _, err := s.getSubscription(ctx, userID, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetDeliveriesBySubscriptionID(ctx, subscriptionID, maxDeliveryHistory)
+}
+
+// ReplayDelivery puts a dead delivery of the user back into the queue with a fresh set of attempts
+func (s *WebhookService) ReplayDelivery(ctx context.Context, userID, deliveryID int64) (*entity.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ReplayDelivery")
+	defer span.End()
+
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	// Deliveries of other users' subscriptions are reported as missing
+	if _, err := s.getSubscription(ctx, userID, delivery.SubscriptionID); err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	if delivery.Status != entity.DeliveryDead {
+		return nil, ErrDeliveryNotReplayable
+	}
+
+	delivery.Status = entity.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+
+	if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// Start starts queueing events and delivering them
+func (s *WebhookService) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.deliverQueued(ctx)
+}
+
+// Stop stops queueing events and delivering them
+func (s *WebhookService) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// deliverQueued periodically queues new outbox events and delivers due deliveries
+func (s *WebhookService) deliverQueued(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := drainBatches(webhookFanOutBatchSize, func() (int, error) { return s.fanOut(ctx) }); err != nil {
+				s.logger.ErrorContext(ctx, "Failed to queue webhook deliveries", "error", err)
+			}
+			s.deliverDue(ctx)
+		case <-s.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fanOut queues a delivery of each outbox event not yet queued for every subscription interested in it
+// and reports how many events were queued. Queueing and marking the events happen in one transaction,
+// so every event is queued exactly once.
+func (s *WebhookService) fanOut(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.fanOut")
+	defer span.End()
+
+	var queued int
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		events, err := s.outboxRepo.GetNotFannedOut(ctx, webhookFanOutBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(events))
+		for i, event := range events {
+			payload, err := json.Marshal(Event{
+				ID:        uint64(event.ID),
+				Type:      event.Type,
+				Data:      event.Payload,
+				CreatedAt: event.CreatedAt,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to encode webhook payload of event %d: %w", event.ID, err)
+			}
+
+			if _, err := s.repo.Enqueue(ctx, event.UserID, event.Type, payload); err != nil {
+				return err
+			}
+			ids[i] = event.ID
+		}
+
+		if err := s.outboxRepo.MarkFannedOut(ctx, ids); err != nil {
+			return err
+		}
+
+		queued = len(events)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return queued, nil
+}
+
+// deliverDue claims a batch of due deliveries and attempts them concurrently
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "WebhookService.deliverDue")
+	defer span.End()
+
+	deliveries, err := s.repo.ClaimDue(ctx, webhookBatchSize, webhookLease)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to claim webhook deliveries", "error", err)
+		return
+	}
+
+	subscriptions := make(map[int64]*entity.WebhookSubscription)
+	for _, delivery := range deliveries {
+		if _, ok := subscriptions[delivery.SubscriptionID]; ok {
+			continue
+		}
+
+		subscription, err := s.repo.GetSubscription(ctx, delivery.SubscriptionID)
+		if err != nil {
+			// The subscription was deleted after the claim, its deliveries are gone with it
+			s.logger.WarnContext(ctx, "Failed to get webhook subscription", "subscription_id", delivery.SubscriptionID, "error", err)
+			continue
+		}
+		subscriptions[delivery.SubscriptionID] = subscription
+	}
+
+	queue := make(chan *entity.WebhookDelivery)
+	var wg sync.WaitGroup
+	for range min(webhookWorkers, len(deliveries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for delivery := range queue {
+				s.attempt(ctx, subscriptions[delivery.SubscriptionID], delivery)
+
+				if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+					s.logger.ErrorContext(ctx, "Failed to update webhook delivery", "delivery_id", delivery.ID, "error", err)
+				}
+			}
+		}()
+	}
+
+	for i := range deliveries {
+		if _, ok := subscriptions[deliveries[i].SubscriptionID]; ok {
+			queue <- &deliveries[i]
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// attempt sends a delivery once and records the outcome on it
+func (s *WebhookService) attempt(ctx context.Context, subscription *entity.WebhookSubscription, delivery *entity.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := s.send(ctx, subscription, delivery)
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = entity.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxWebhookErrorLength {
+		delivery.LastError = delivery.LastError[:maxWebhookErrorLength]
+	}
+
+	if delivery.Attempts >= s.maxAttempts {
+		delivery.Status = entity.DeliveryDead
+		s.logger.WarnContext(ctx, "Webhook delivery is dead",
+			"delivery_id", delivery.ID, "subscription_id", subscription.ID, "attempts", delivery.Attempts, "error", err)
+		return
+	}
+
+	delivery.NextAttemptAt = time.Now().Add(s.backoff(delivery.Attempts))
+}
+
+// send posts the signed payload of a delivery to the subscription URL
+func (s *WebhookService) send(
+	ctx context.Context,
+	subscription *entity.WebhookSubscription,
+	delivery *entity.WebhookDelivery,
+) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(subscription.Secret, time.Now(), delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt, doubling with every failed attempt
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.baseBackoff
+	for i := 1; i < attempts && delay < maxWebhookBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookBackoff)
+}
+
+// getSubscription retrieves a subscription and checks that it belongs to the user
+func (s *WebhookService) getSubscription(ctx context.Context, userID, subscriptionID int64) (*entity.WebhookSubscription, error) {
+	subscription, err := s.repo.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+
+	if subscription.UserID != userID {
+		return nil, ErrWebhookNotFound
+	}
+
+	return subscription, nil
+}
+
+// SignWebhookPayload returns the signature header value for a payload sent at the given time.
+// The signature is the hex HMAC-SHA256 of "<unix timestamp>.<payload>" keyed with the subscription secret.
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gophermart/domain/entity"
+	"gophermart/internal/sqlite"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookFixture is a webhook service backed by a fresh SQLite database with one user
+type webhookFixture struct {
+	service *WebhookService
+	outbox  *sqlite.OutboxRepo
+	webhook *sqlite.WebhookRepo
+	userID  int64
+}
+
+// newWebhookFixture creates a webhook service with the given private address policy
+func newWebhookFixture(t *testing.T, allowPrivate bool) *webhookFixture {
+	t.Helper()
+
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	user := &entity.User{Login: "alice", Password: "hash", Role: entity.RoleUser}
+	if err := sqlite.NewUserRepo(db).Create(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	outbox := sqlite.NewOutboxRepo(db)
+	webhook := sqlite.NewWebhookRepo(db)
+
+	return &webhookFixture{
+		service: NewWebhookService(webhook, outbox, sqlite.NewTransactor(db), 3, time.Second, time.Second, allowPrivate, discardLogger()),
+		outbox:  outbox,
+		webhook: webhook,
+		userID:  user.ID,
+	}
+}
+
+// subscribe stores a subscription without the checks of CreateSubscription
+func (f *webhookFixture) subscribe(t *testing.T, url string, eventTypes ...string) *entity.WebhookSubscription {
+	t.Helper()
+
+	subscription := &entity.WebhookSubscription{UserID: f.userID, URL: url, EventTypes: eventTypes, Secret: "secret"}
+	if err := f.webhook.CreateSubscription(context.Background(), subscription); err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	return subscription
+}
+
+// record adds outbox events of the user and queues their deliveries
+func (f *webhookFixture) record(t *testing.T, events ...Event) {
+	t.Helper()
+
+	ctx := context.Background()
+	for i := range events {
+		events[i].UserID = f.userID
+	}
+	if err := recordEvents(ctx, f.outbox, events...); err != nil {
+		t.Fatalf("failed to record events: %v", err)
+	}
+	if _, err := f.service.fanOut(ctx); err != nil {
+		t.Fatalf("failed to queue deliveries: %v", err)
+	}
+}
+
+// deliveries returns the deliveries of a subscription
+func (f *webhookFixture) deliveries(t *testing.T, subscription *entity.WebhookSubscription) []entity.WebhookDelivery {
+	t.Helper()
+
+	deliveries, err := f.webhook.GetDeliveriesBySubscriptionID(context.Background(), subscription.ID, maxDeliveryHistory)
+	if err != nil {
+		t.Fatalf("failed to get deliveries: %v", err)
+	}
+
+	return deliveries
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::6810:84e5", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCreateSubscriptionRejectsNonPublicURL(t *testing.T) {
+	f := newWebhookFixture(t, false)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://does-not-exist.invalid/hook",
+	} {
+		_, err := f.service.CreateSubscription(context.Background(), f.userID, url, []string{EventOrderStatus}, "secret")
+		if !errors.Is(err, ErrWebhookURLNotAllowed) {
+			t.Errorf("CreateSubscription(%s) error = %v, want %v", url, err, ErrWebhookURLNotAllowed)
+		}
+	}
+
+	if _, err := f.service.CreateSubscription(context.Background(), f.userID, "https://93.184.216.34/hook", []string{EventOrderStatus}, "secret"); err != nil {
+		t.Errorf("CreateSubscription with a public address failed: %v", err)
+	}
+}
+
+func TestDeliveryRefusesPrivateAddressAtDialTime(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	// The subscription passed the check when it was created, its host resolves to loopback now
+	f := newWebhookFixture(t, false)
+	subscription := f.subscribe(t, server.URL, EventOrderStatus)
+
+	f.record(t, Event{Type: EventOrderStatus, Data: OrderStatusEvent{Order: "79927398713", Status: "NEW"}})
+	f.service.deliverDue(context.Background())
+
+	if n := requests.Load(); n != 0 {
+		t.Errorf("server received %d requests, want none", n)
+	}
+
+	deliveries := f.deliveries(t, subscription)
+	if len(deliveries) != 1 || !strings.Contains(deliveries[0].LastError, "not public") {
+		t.Errorf("deliveries = %+v, want one failed with a non-public address", deliveries)
+	}
+}
+
+func TestFanOutQueuesOutboxEventsOnce(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	f := newWebhookFixture(t, true)
+	subscription := f.subscribe(t, server.URL, EventOrderStatus)
+
+	f.record(t,
+		Event{Type: EventOrderStatus, Data: OrderStatusEvent{Order: "79927398713", Status: "PROCESSING"}},
+		Event{Type: EventBalance, Data: map[string]float64{"current": 10}},
+		Event{Type: EventOrderStatus, Data: OrderStatusEvent{Order: "79927398713", Status: "PROCESSED", Accrual: 10}},
+	)
+
+	// Events that were queued are not queued again
+	if queued, err := f.service.fanOut(context.Background()); err != nil || queued != 0 {
+		t.Fatalf("second fan-out queued %d events (error %v), want none", queued, err)
+	}
+
+	f.service.deliverDue(context.Background())
+
+	for _, delivery := range f.deliveries(t, subscription) {
+		if delivery.Status != entity.DeliveryDelivered {
+			t.Errorf("delivery %d is %s, want %s", delivery.ID, delivery.Status, entity.DeliveryDelivered)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != 2 {
+		t.Fatalf("server received %d events, want 2", len(received))
+	}
+	for _, event := range received {
+		if event.Type != EventOrderStatus || event.ID == 0 {
+			t.Errorf("received event %+v, want an order status event with an ID", event)
+		}
+	}
+}
+
+func TestDeliverDueSendsConcurrently(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		time.Sleep(delay)
+	}))
+	defer server.Close()
+
+	f := newWebhookFixture(t, true)
+	subscription := f.subscribe(t, server.URL, EventOrderStatus)
+
+	events := make([]Event, 2*webhookWorkers)
+	for i := range events {
+		events[i] = Event{Type: EventOrderStatus, Data: OrderStatusEvent{Order: "79927398713", Status: "PROCESSING"}}
+	}
+	f.record(t, events...)
+
+	start := time.Now()
+	f.service.deliverDue(context.Background())
+	if elapsed := time.Since(start); elapsed >= time.Duration(len(events))*delay/2 {
+		t.Errorf("delivering %d events took %s, deliveries are not sent concurrently", len(events), elapsed)
+	}
+
+	for _, delivery := range f.deliveries(t, subscription) {
+		if delivery.Status != entity.DeliveryDelivered {
+			t.Errorf("delivery %d is %s, want %s", delivery.ID, delivery.Status, entity.DeliveryDelivered)
+		}
+	}
+}
+
+func TestWebhookBatchFitsLease(t *testing.T) {
+	rounds := (webhookBatchSize + webhookWorkers - 1) / webhookWorkers
+	if worst := time.Duration(rounds) * webhookTimeout; worst >= webhookLease {
+		t.Errorf("a batch may take %s, which is not within the %s lease", worst, webhookLease)
+	}
+}
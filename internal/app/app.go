@@ -2,23 +2,54 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gophermart/domain/service"
+	"gophermart/internal/grpc"
+	"gophermart/internal/health"
 	"gophermart/internal/http"
+	"log/slog"
 	"time"
 )
 
 // App represents the main application
 type App struct {
-	server         *http.Server
-	accrualService *service.AccrualService
+	server             *http.Server
+	grpcServer         *grpc.Server
+	accrualService     *service.AccrualService
+	idempotencyService *service.IdempotencyService
+	webhookService     *service.WebhookService
+	outboxRelay        *service.OutboxRelay
+	health             *health.Checker
+	shutdownDelay      time.Duration
+	shutdownTimeout    time.Duration
+	logger             *slog.Logger
 }
 
 // NewApp creates a new application
-func NewApp(server *http.Server, accrualService *service.AccrualService) *App {
+func NewApp(
+	server *http.Server,
+	grpcServer *grpc.Server,
+	accrualService *service.AccrualService,
+	idempotencyService *service.IdempotencyService,
+	webhookService *service.WebhookService,
+	outboxRelay *service.OutboxRelay,
+	health *health.Checker,
+	shutdownDelay time.Duration,
+	shutdownTimeout time.Duration,
+	logger *slog.Logger,
+) *App {
 	return &App{
-		server:         server,
-		accrualService: accrualService,
+		server:             server,
+		grpcServer:         grpcServer,
+		accrualService:     accrualService,
+		idempotencyService: idempotencyService,
+		webhookService:     webhookService,
+		outboxRelay:        outboxRelay,
+		health:             health,
+		shutdownDelay:      shutdownDelay,
+		shutdownTimeout:    shutdownTimeout,
+		logger:             logger,
 	}
 }
 
@@ -27,32 +58,70 @@ func (a *App) Start(ctx context.Context) error {
 	// Start accrual service
 	a.accrualService.Start(ctx)
 
-	// Run HTTP server in a goroutine
-	errCh := make(chan error, 1)
+	// Start removal of expired idempotency records
+	a.idempotencyService.Start(ctx)
+
+	// Start queueing and delivering webhooks
+	a.webhookService.Start(ctx)
+
+	// Start publishing domain events from the outbox
+	a.outboxRelay.Start(ctx)
+
+	// Run HTTP and gRPC servers in goroutines
+	errCh := make(chan error, 2)
 	go func() {
-		fmt.Println("Starting server...")
+		a.logger.Info("Starting server")
 		errCh <- a.server.Start()
 	}()
+	go func() {
+		a.logger.Info("Starting gRPC server")
+		if err := a.grpcServer.Start(); err != nil {
+			errCh <- fmt.Errorf("gRPC: %w", err)
+		}
+	}()
 
 	// Wait for context cancellation or server error
 	select {
 	case <-ctx.Done():
-		fmt.Println("Shutting down gracefully...")
+		a.logger.Info("Shutting down gracefully")
 
-		// Create a timeout context for shutdown
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		// Fail readiness first and keep serving while load balancers notice and drain traffic
+		a.health.SetShuttingDown()
+		if a.shutdownDelay > 0 {
+			a.logger.Info("Draining traffic", "delay", a.shutdownDelay)
+			time.Sleep(a.shutdownDelay)
+		}
 
 		// Stop accrual service
 		a.accrualService.Stop()
+		a.idempotencyService.Stop()
+		a.webhookService.Stop()
+		a.outboxRelay.Stop()
+
+		// Shutdown the servers side by side, so that a slow one does not use up the time of the other
+		grpcErrCh := make(chan error, 1)
+		go func() {
+			grpcErrCh <- a.shutdown(a.grpcServer.Shutdown)
+		}()
 
-		// Shutdown the server
-		if err := a.server.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("error during server shutdown: %w", err)
+		var errs []error
+		if err := a.shutdown(a.server.Shutdown); err != nil {
+			errs = append(errs, fmt.Errorf("error during server shutdown: %w", err))
+		}
+		if err := <-grpcErrCh; err != nil {
+			errs = append(errs, fmt.Errorf("error during gRPC server shutdown: %w", err))
 		}
 
-		return nil
+		return errors.Join(errs...)
 	case err := <-errCh:
 		return fmt.Errorf("server error: %w", err)
 	}
 }
+
+// shutdown stops a server, giving it the shutdown timeout to finish its requests
+func (a *App) shutdown(stop func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	return stop(ctx)
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"gophermart/domain/service"
+)
+
+// ErrUnauthenticated is returned for missing, invalid, expired or revoked session tokens
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticate validates a session token and checks that the session is still valid for the user
+func Authenticate(ctx context.Context, userService *service.UserService, token string) (*Claims, error) {
+	claims, err := ValidateToken(token)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	// Reject tokens revoked by a password change or reset, or issued before a role change
+	user, err := userService.ValidateSession(ctx, claims.UserID, claims.TokenVersion)
+	if err != nil || user.Role != claims.Role {
+		return nil, ErrUnauthenticated
+	}
+
+	return claims, nil
+}
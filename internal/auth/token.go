@@ -0,0 +1,109 @@
+// Package auth issues and validates the JWTs shared by the HTTP and gRPC APIs
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var jwtSecret = []byte("your-secret-key") // In production, use a strong, secure key
+
+// Token audiences keep partial two-factor challenge tokens from being accepted as sessions
+const (
+	audienceSession   = "gophermart"
+	audienceChallenge = "gophermart-2fa"
+)
+
+// SessionTTL is the lifetime of a session token
+const SessionTTL = 24 * time.Hour
+
+// challengeTTL is the time a user has to supply the second factor after the password
+const challengeTTL = 5 * time.Minute
+
+// Claims represents JWT claims
+type Claims struct {
+	UserID       int64  `json:"user_id"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"token_version"`
+	jwt.RegisteredClaims
+}
+
+// GenerateToken generates a JWT session token for a user
+func GenerateToken(user *entity.User) (string, error) {
+	return signToken(user, audienceSession, SessionTTL)
+}
+
+// GenerateChallengeToken generates a short-lived token proving the password step of a two-factor login
+func GenerateChallengeToken(user *entity.User) (string, error) {
+	return signToken(user, audienceChallenge, challengeTTL)
+}
+
+// signToken signs claims for the given audience and lifetime
+func signToken(user *entity.User, audience string, ttl time.Duration) (string, error) {
+	expirationTime := time.Now().Add(ttl)
+	claims := &Claims{
+		UserID:       user.ID,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return SignClaims(claims)
+}
+
+// SignClaims signs arbitrary claims with the application key
+func SignClaims(claims jwt.Claims) (string, error) {
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// ParseClaims verifies a token signed with SignClaims and decodes it into claims
+func ParseClaims(tokenString string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateToken validates a JWT session token and returns its claims
+func ValidateToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, audienceSession)
+}
+
+// ValidateChallengeToken validates a two-factor challenge token and returns its claims
+func ValidateChallengeToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, audienceChallenge)
+}
+
+// parseToken validates a JWT token issued for the given audience
+func parseToken(tokenString, audience string) (*Claims, error) {
+	claims := &Claims{}
+	if err := ParseClaims(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyAudience(audience, true) {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvRejectsMalformedValues(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "abc")
+	t.Setenv("MIGRATE_ON_START", "yes please")
+	t.Setenv("SHUTDOWN_DELAY", "5")
+
+	conns, migrate, delay := 25, true, 5*time.Second
+	if err := envInt("DB_MAX_OPEN_CONNS", &conns); err == nil {
+		t.Error("envInt accepted \"abc\"")
+	}
+	if err := envBool("MIGRATE_ON_START", &migrate); err == nil {
+		t.Error("envBool accepted \"yes please\"")
+	}
+	if err := envDuration("SHUTDOWN_DELAY", &delay); err == nil {
+		t.Error("envDuration accepted a duration without unit")
+	}
+	if conns != 25 || !migrate || delay != 5*time.Second {
+		t.Errorf("malformed values changed the settings to %d, %v, %s", conns, migrate, delay)
+	}
+}
+
+func TestEnvOverridesValidValues(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "10")
+	t.Setenv("MIGRATE_ON_START", "false")
+	t.Setenv("SHUTDOWN_DELAY", "2s")
+
+	conns, migrate, delay := 25, true, 5*time.Second
+	if err := envInt("DB_MAX_OPEN_CONNS", &conns); err != nil || conns != 10 {
+		t.Errorf("envInt = %d, %v, want 10", conns, err)
+	}
+	if err := envBool("MIGRATE_ON_START", &migrate); err != nil || migrate {
+		t.Errorf("envBool = %v, %v, want false", migrate, err)
+	}
+	if err := envDuration("SHUTDOWN_DELAY", &delay); err != nil || delay != 2*time.Second {
+		t.Errorf("envDuration = %s, %v, want 2s", delay, err)
+	}
+}
@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"context"
+	"gophermart/api/gophermartpb"
+	"gophermart/internal/auth"
+	"gophermart/internal/logging"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// userIDKey is the context key of the authenticated user ID
+type userIDKey struct{}
+
+// publicMethods can be called without a session token
+var publicMethods = map[string]bool{
+	gophermartpb.UserService_Register_FullMethodName:          true,
+	gophermartpb.UserService_Login_FullMethodName:             true,
+	gophermartpb.UserService_LoginSecondFactor_FullMethodName: true,
+}
+
+// authInterceptor authenticates calls by the Bearer token in the authorization metadata
+// and adds the method and the user to the log records of the call
+func (s *Server) authInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx = logging.WithAttrs(ctx, slog.String("method", info.FullMethod))
+
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	claims, err := auth.Authenticate(ctx, s.userService, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	ctx = logging.WithAttrs(ctx, slog.Int64("user_id", claims.UserID))
+	return handler(context.WithValue(ctx, userIDKey{}, claims.UserID), req)
+}
+
+// userID returns the ID of the user authenticated by authInterceptor
+func userID(ctx context.Context) int64 {
+	id, _ := ctx.Value(userIDKey{}).(int64)
+	return id
+}
@@ -0,0 +1,88 @@
+package grpc
+
+import (
+	"context"
+	"gophermart/api/gophermartpb"
+	"gophermart/domain/entity"
+	"gophermart/domain/service"
+	"gophermart/internal/logging"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// balanceServer implements the BalanceService gRPC service
+type balanceServer struct {
+	gophermartpb.UnimplementedBalanceServiceServer
+	*Server
+}
+
+// GetBalance retrieves the user's balance
+func (s *balanceServer) GetBalance(ctx context.Context, _ *gophermartpb.GetBalanceRequest) (*gophermartpb.Balance, error) {
+	balance, err := s.balanceService.GetUserBalance(ctx, userID(ctx))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return &gophermartpb.Balance{Current: balance.Current, Withdrawn: balance.Withdrawn}, nil
+}
+
+// Withdraw withdraws points to pay for an order
+func (s *balanceServer) Withdraw(ctx context.Context, req *gophermartpb.WithdrawRequest) (*gophermartpb.WithdrawResponse, error) {
+	if req.GetOrder() == "" || req.GetSum() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid withdrawal request")
+	}
+	ctx = logging.WithAttrs(ctx, slog.String("order_id", req.GetOrder()))
+
+	if err := s.balanceService.WithdrawPoints(ctx, userID(ctx), req.GetOrder(), req.GetSum()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return &gophermartpb.WithdrawResponse{}, nil
+}
+
+// ListWithdrawals retrieves a page of the user's withdrawals
+func (s *balanceServer) ListWithdrawals(
+	ctx context.Context,
+	req *gophermartpb.ListWithdrawalsRequest,
+) (*gophermartpb.ListWithdrawalsResponse, error) {
+	params := service.ListParams{
+		Cursor:    req.GetCursor(),
+		Limit:     int(req.GetLimit()),
+		From:      toTime(req.GetFrom()),
+		To:        toTime(req.GetTo()),
+		Ascending: req.GetAscending(),
+	}
+
+	page, err := s.balanceService.ListUserWithdrawals(ctx, userID(ctx), params)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	response := &gophermartpb.ListWithdrawalsResponse{NextCursor: page.NextCursor}
+	for _, withdrawal := range page.Withdrawals {
+		response.Withdrawals = append(response.Withdrawals, toWithdrawal(withdrawal))
+	}
+
+	return response, nil
+}
+
+// toWithdrawal converts a withdrawal to its protobuf message
+func toWithdrawal(withdrawal entity.Withdrawal) *gophermartpb.Withdrawal {
+	return &gophermartpb.Withdrawal{
+		Order:       withdrawal.OrderID,
+		Sum:         withdrawal.Sum,
+		ProcessedAt: timestamppb.New(withdrawal.ProcessedAt),
+	}
+}
+
+// toTime converts an optional timestamp, nil means the zero time
+func toTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,58 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"gophermart/domain/service"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorMapping maps a domain error to a gRPC status code
+type errorMapping struct {
+	err     error
+	code    codes.Code
+	message string
+}
+
+// errorMappings translates domain errors into gRPC statuses, mirroring the HTTP problem types
+var errorMappings = []errorMapping{
+	{service.ErrUserExists, codes.AlreadyExists, "User already exists"},
+	{service.ErrUserNotFound, codes.NotFound, "User not found"},
+	{service.ErrInvalidCredentials, codes.Unauthenticated, "Invalid credentials"},
+	{service.ErrAccountBlocked, codes.PermissionDenied, "Account blocked"},
+	{service.ErrSessionRevoked, codes.Unauthenticated, "Session revoked"},
+	{service.ErrTwoFactorNotEnabled, codes.FailedPrecondition, "Two-factor authentication not enabled"},
+	{service.ErrInvalidTwoFactor, codes.Unauthenticated, "Invalid two-factor code"},
+	{service.ErrTwoFactorLocked, codes.ResourceExhausted, "Too many failed two-factor attempts"},
+	{service.ErrInvalidOrderNumber, codes.InvalidArgument, "Invalid order number format"},
+	{service.ErrOrderNotFound, codes.NotFound, "Order not found"},
+	{service.ErrOrderOwnedByOther, codes.AlreadyExists, "Order already uploaded by another user"},
+	{service.ErrOrderExists, codes.AlreadyExists, "Order already exists"},
+	{service.ErrEmptyBatch, codes.InvalidArgument, "Batch contains no orders"},
+	{service.ErrBatchTooLarge, codes.InvalidArgument, "Batch contains too many orders"},
+	{service.ErrAccrualUnavailable, codes.Unavailable, "Accrual system unavailable"},
+	{service.ErrInsufficientFunds, codes.FailedPrecondition, "Insufficient funds"},
+	{service.ErrInvalidCursor, codes.InvalidArgument, "Invalid cursor"},
+	{service.ErrInvalidListParams, codes.InvalidArgument, "Invalid list parameters"},
+}
+
+// toStatus translates a service error into a gRPC status error
+func (s *Server) toStatus(ctx context.Context, err error) error {
+	var policyErr *service.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return status.Error(codes.InvalidArgument, policyErr.Error())
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return status.Error(m.code, m.message)
+		}
+	}
+
+	// Unknown errors are internal, their text is not exposed to clients
+	s.logger.LogAttrs(ctx, slog.LevelError, "Call failed", slog.Any("error", err))
+	return status.Error(codes.Internal, "Internal error")
+}
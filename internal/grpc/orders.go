@@ -0,0 +1,100 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"gophermart/api/gophermartpb"
+	"gophermart/domain/entity"
+	"gophermart/domain/service"
+	"gophermart/internal/logging"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// orderServer implements the OrderService gRPC service
+type orderServer struct {
+	gophermartpb.UnimplementedOrderServiceServer
+	*Server
+}
+
+// UploadOrder uploads a new order
+func (s *orderServer) UploadOrder(ctx context.Context, req *gophermartpb.UploadOrderRequest) (*gophermartpb.UploadOrderResponse, error) {
+	orderID := strings.TrimSpace(req.GetNumber())
+	if orderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "Order ID is required")
+	}
+	ctx = logging.WithAttrs(ctx, slog.String("order_id", orderID))
+
+	_, err := s.orderService.UploadOrder(ctx, orderID, userID(ctx))
+	if err != nil {
+		if errors.Is(err, service.ErrOrderAlreadyUploaded) {
+			return &gophermartpb.UploadOrderResponse{AlreadyUploaded: true}, nil
+		}
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return &gophermartpb.UploadOrderResponse{}, nil
+}
+
+// UploadOrders uploads a batch of orders and reports the outcome of each
+func (s *orderServer) UploadOrders(ctx context.Context, req *gophermartpb.UploadOrdersRequest) (*gophermartpb.UploadOrdersResponse, error) {
+	orderIDs := make([]string, len(req.GetNumbers()))
+	for i, number := range req.GetNumbers() {
+		orderIDs[i] = strings.TrimSpace(number)
+	}
+
+	results, err := s.orderService.UploadOrders(ctx, orderIDs, userID(ctx))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	response := &gophermartpb.UploadOrdersResponse{}
+	for _, result := range results {
+		response.Results = append(response.Results, &gophermartpb.OrderUploadResult{
+			Number: result.Order,
+			Status: result.Status,
+		})
+	}
+
+	return response, nil
+}
+
+// ListOrders retrieves a page of the user's orders
+func (s *orderServer) ListOrders(ctx context.Context, req *gophermartpb.ListOrdersRequest) (*gophermartpb.ListOrdersResponse, error) {
+	params := service.ListParams{
+		Cursor:    req.GetCursor(),
+		Limit:     int(req.GetLimit()),
+		From:      toTime(req.GetFrom()),
+		To:        toTime(req.GetTo()),
+		Ascending: req.GetAscending(),
+	}
+	for _, orderStatus := range req.GetStatuses() {
+		params.Statuses = append(params.Statuses, strings.ToUpper(strings.TrimSpace(orderStatus)))
+	}
+
+	page, err := s.orderService.ListUserOrders(ctx, userID(ctx), params)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	response := &gophermartpb.ListOrdersResponse{NextCursor: page.NextCursor}
+	for _, order := range page.Orders {
+		response.Orders = append(response.Orders, toOrder(order))
+	}
+
+	return response, nil
+}
+
+// toOrder converts an order to its protobuf message
+func toOrder(order entity.Order) *gophermartpb.Order {
+	return &gophermartpb.Order{
+		Number:     order.ID,
+		Status:     order.Status,
+		Accrual:    order.Accrual,
+		UploadedAt: timestamppb.New(order.UploadedAt),
+	}
+}
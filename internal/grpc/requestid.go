@@ -0,0 +1,33 @@
+package grpc
+
+import (
+	"context"
+	"gophermart/internal/logging"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDMetadataKey is the metadata key of the request ID, gRPC metadata keys are lowercase
+var requestIDMetadataKey = strings.ToLower(logging.RequestIDHeader)
+
+// requestIDInterceptor propagates the request ID of the call metadata or generates a new one,
+// returns it in the response header and adds it to the context for downstream logs
+func requestIDInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	var received string
+	if values := metadata.ValueFromIncomingContext(ctx, requestIDMetadataKey); len(values) > 0 {
+		received = values[0]
+	}
+
+	id := logging.AcceptRequestID(received)
+	// The header cannot be set when the call has no transport stream, as in direct handler calls
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, id))
+
+	return handler(logging.WithRequestID(ctx, id), req)
+}
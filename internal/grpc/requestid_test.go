@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"gophermart/internal/logging"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		received string
+		keep     bool
+	}{
+		{"propagates the received ID", "abc-123", true},
+		{"generates a missing ID", "", false},
+		{"replaces an invalid ID", "has space", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.received != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(requestIDMetadataKey, tt.received))
+			}
+
+			var got string
+			_, err := requestIDInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+				got = logging.RequestID(ctx)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("interceptor failed: %v", err)
+			}
+
+			if got == "" || (got == tt.received) != tt.keep {
+				t.Errorf("request ID = %q for received %q", got, tt.received)
+			}
+		})
+	}
+}
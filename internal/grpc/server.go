@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	"gophermart/api/gophermartpb"
+	"gophermart/domain/service"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+)
+
+// Server represents the gRPC server
+type Server struct {
+	addr           string
+	server         *grpc.Server
+	userService    *service.UserService
+	orderService   *service.OrderService
+	balanceService *service.BalanceService
+	logger         *slog.Logger
+}
+
+// NewServer creates a new gRPC server
+func NewServer(
+	addr string,
+	userService *service.UserService,
+	orderService *service.OrderService,
+	balanceService *service.BalanceService,
+	logger *slog.Logger,
+) *Server {
+	server := &Server{
+		addr:           addr,
+		userService:    userService,
+		orderService:   orderService,
+		balanceService: balanceService,
+		logger:         logger,
+	}
+
+	server.server = grpc.NewServer(grpc.ChainUnaryInterceptor(requestIDInterceptor, tracingInterceptor, server.authInterceptor))
+	gophermartpb.RegisterUserServiceServer(server.server, &userServer{Server: server})
+	gophermartpb.RegisterOrderServiceServer(server.server, &orderServer{Server: server})
+	gophermartpb.RegisterBalanceServiceServer(server.server, &balanceServer{Server: server})
+
+	return server
+}
+
+// Start starts the gRPC server
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	return s.server.Serve(listener)
+}
+
+// Shutdown gracefully shuts down the server, closing remaining connections when ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
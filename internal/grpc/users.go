@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"gophermart/api/gophermartpb"
+	"gophermart/domain/entity"
+	"gophermart/domain/service"
+	"gophermart/internal/auth"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userServer implements the UserService gRPC service
+type userServer struct {
+	gophermartpb.UnimplementedUserServiceServer
+	*Server
+}
+
+// Register registers a user and returns a session token
+func (s *userServer) Register(ctx context.Context, req *gophermartpb.RegisterRequest) (*gophermartpb.AuthResponse, error) {
+	if req.GetLogin() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Login and password are required")
+	}
+
+	user, err := s.userService.Register(ctx, req.GetLogin(), req.GetPassword())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return sessionResponse(user)
+}
+
+// Login returns a session token, or a challenge token when a second factor is required
+func (s *userServer) Login(ctx context.Context, req *gophermartpb.LoginRequest) (*gophermartpb.AuthResponse, error) {
+	if req.GetLogin() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Login and password are required")
+	}
+
+	user, err := s.userService.Login(ctx, req.GetLogin(), req.GetPassword())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	// Users with two-factor authentication get a challenge instead of a session
+	if user.TOTPEnabled {
+		challenge, err := auth.GenerateChallengeToken(user)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "Failed to generate token")
+		}
+
+		return &gophermartpb.AuthResponse{ChallengeToken: challenge}, nil
+	}
+
+	return sessionResponse(user)
+}
+
+// LoginSecondFactor completes a two-factor login
+func (s *userServer) LoginSecondFactor(
+	ctx context.Context,
+	req *gophermartpb.LoginSecondFactorRequest,
+) (*gophermartpb.AuthResponse, error) {
+	if req.GetChallengeToken() == "" || req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Challenge token and code are required")
+	}
+
+	claims, err := auth.ValidateChallengeToken(req.GetChallengeToken())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid challenge token")
+	}
+
+	if _, err := s.userService.ValidateSession(ctx, claims.UserID, claims.TokenVersion); err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid challenge token")
+	}
+
+	user, err := s.userService.VerifySecondFactor(ctx, claims.UserID, req.GetCode())
+	if err != nil {
+		// During login a wrong code means the user is not authenticated yet
+		if errors.Is(err, service.ErrInvalidTwoFactor) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid two-factor code")
+		}
+		return nil, s.toStatus(ctx, err)
+	}
+
+	return sessionResponse(user)
+}
+
+// sessionResponse generates a session token for the user
+func sessionResponse(user *entity.User) (*gophermartpb.AuthResponse, error) {
+	token, err := auth.GenerateToken(user)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to generate token")
+	}
+
+	return &gophermartpb.AuthResponse{Token: token}, nil
+}
@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a check and of the whole report
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// checkTimeout bounds each readiness check so a hanging dependency cannot stall the probe
+const checkTimeout = 2 * time.Second
+
+// errShuttingDown is reported once graceful shutdown has started
+var errShuttingDown = errors.New("shutting down")
+
+// Check reports whether a dependency can serve requests
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single check. The error is only for the server log,
+// it can name hosts and drivers and is left out of the unauthenticated report.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"-"`
+	Optional bool   `json:"optional,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of all readiness checks
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// namedCheck is a registered check
+type namedCheck struct {
+	name     string
+	check    Check
+	optional bool
+}
+
+// Checker runs the readiness checks of the service
+type Checker struct {
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+// NewChecker creates a Checker without checks, it is ready until shutdown starts
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add registers a check that makes the service not ready when it fails
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// AddOptional registers a check that is reported but does not affect readiness
+func (c *Checker) AddOptional(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check, optional: true})
+}
+
+// SetShuttingDown makes the service not ready so load balancers stop sending it traffic
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Ready runs all checks concurrently and reports whether the service is ready
+func (c *Checker) Ready(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks)+1)}
+
+	shutdown := CheckResult{Status: StatusOK, Duration: "0s"}
+	if c.shuttingDown.Load() {
+		shutdown = CheckResult{Status: StatusFail, Error: errShuttingDown.Error(), Duration: "0s"}
+		report.Status = StatusFail
+	}
+	report.Checks["shutdown"] = shutdown
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := run(ctx, nc)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = result
+			if result.Status == StatusFail && !nc.optional {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run runs a single check within checkTimeout
+func run(ctx context.Context, nc namedCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := nc.check(ctx)
+	result := CheckResult{Status: StatusOK, Optional: nc.optional, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
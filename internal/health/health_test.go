@@ -0,0 +1,32 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadyLeavesErrorsOutOfTheReport(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(context.Context) error {
+		return errors.New("dial tcp db.internal:5432: connection refused")
+	})
+
+	report := checker.Ready(context.Background())
+	if report.Status != StatusFail || report.Checks["database"].Status != StatusFail {
+		t.Fatalf("report = %+v, want a failing database check", report)
+	}
+	if report.Checks["database"].Error == "" {
+		t.Error("the failing check has no error to log")
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("failed to encode report: %v", err)
+	}
+	if strings.Contains(string(body), "db.internal") {
+		t.Errorf("report %s contains the error of the check", body)
+	}
+}
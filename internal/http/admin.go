@@ -0,0 +1,212 @@
+package http
+
+import (
+	"encoding/json"
+	"gophermart/internal/logging"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// RoleRequest represents a request to change a user's role
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+// setUserRole changes the role of the user identified in the path
+func (s *Server) setUserRole(w http.ResponseWriter, r *http.Request, adminID int64) {
+	if r.Method != http.MethodPut {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	// Prevent admins from locking themselves out of the back office
+	if userID == adminID {
+		writeProblem(w, r, http.StatusConflict, "Cannot change own role")
+		return
+	}
+
+	user, err := s.userService.SetRole(r.Context(), userID, req.Role)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// BalanceAdjustmentRequest represents a manual balance adjustment request
+type BalanceAdjustmentRequest struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+// searchUsers finds users by login
+func (s *Server) searchUsers(w http.ResponseWriter, r *http.Request, _ int64) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	users, err := s.adminService.SearchUsers(r.Context(), r.URL.Query().Get("login"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	if len(users) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+// getUserOverview retrieves a user with balance, orders and withdrawals
+func (s *Server) getUserOverview(w http.ResponseWriter, r *http.Request, _ int64) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	overview, err := s.adminService.GetUserOverview(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, overview)
+}
+
+// recheckOrder forces a re-check of an order against the accrual system
+func (s *Server) recheckOrder(w http.ResponseWriter, r *http.Request, _ int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	r = r.WithContext(logging.WithAttrs(r.Context(), slog.String("order_id", r.PathValue("id"))))
+
+	order, err := s.adminService.RecheckOrder(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+// adjustBalance manually credits or debits a user's balance
+func (s *Server) adjustBalance(w http.ResponseWriter, r *http.Request, adminID int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req BalanceAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	adjustment, err := s.adminService.AdjustBalance(r.Context(), adminID, userID, req.Amount, req.Reason)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, adjustment)
+}
+
+// blockUser blocks a user account
+func (s *Server) blockUser(w http.ResponseWriter, r *http.Request, adminID int64) {
+	s.setUserBlocked(w, r, adminID, true)
+}
+
+// unblockUser unblocks a user account
+func (s *Server) unblockUser(w http.ResponseWriter, r *http.Request, adminID int64) {
+	s.setUserBlocked(w, r, adminID, false)
+}
+
+// setUserBlocked blocks or unblocks the user identified in the path
+func (s *Server) setUserBlocked(w http.ResponseWriter, r *http.Request, adminID int64, blocked bool) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if userID == adminID {
+		writeProblem(w, r, http.StatusConflict, "Cannot block own account")
+		return
+	}
+
+	user, err := s.adminService.SetBlocked(r.Context(), userID, blocked)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// LogLevelRequest represents the current log level or a request to change it
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// handleLogLevel reports or changes the log level at runtime
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request, _ int64) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, LogLevelRequest{Level: s.logLevel.Level().String()})
+	case http.MethodPut:
+		var req LogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+			return
+		}
+
+		level, err := logging.ParseLevel(req.Level)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Unknown log level")
+			return
+		}
+
+		s.logLevel.Set(level)
+		s.logger.InfoContext(r.Context(), "Log level changed", "level", level.String())
+
+		writeJSON(w, http.StatusOK, LogLevelRequest{Level: level.String()})
+	default:
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
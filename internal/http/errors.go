@@ -0,0 +1,121 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"gophermart/domain/service"
+	"log/slog"
+	"net/http"
+)
+
+// problemTypePrefix is prepended to the slug of a mapped error to build the problem type URI
+const problemTypePrefix = "/problems/"
+
+// Problem represents an RFC 7807 problem details response
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Violations lists the failed password policy rules
+	Violations []string `json:"violations,omitempty"`
+}
+
+// errorMapping maps a domain error to an HTTP status and problem type
+type errorMapping struct {
+	err    error
+	status int
+	slug   string
+	title  string
+}
+
+// errorMappings is the single place where domain errors are translated into HTTP responses
+var errorMappings = []errorMapping{
+	{service.ErrUserExists, http.StatusConflict, "user-exists", "User already exists"},
+	{service.ErrUserNotFound, http.StatusNotFound, "user-not-found", "User not found"},
+	{service.ErrInvalidCredentials, http.StatusUnauthorized, "invalid-credentials", "Invalid credentials"},
+	{service.ErrAccountBlocked, http.StatusForbidden, "account-blocked", "Account blocked"},
+	{service.ErrSessionRevoked, http.StatusUnauthorized, "session-revoked", "Session revoked"},
+	{service.ErrInvalidRole, http.StatusBadRequest, "invalid-role", "Invalid role"},
+	{service.ErrInvalidResetToken, http.StatusBadRequest, "invalid-reset-token", "Invalid or expired reset token"},
+	{service.ErrTwoFactorEnabled, http.StatusConflict, "two-factor-enabled", "Two-factor authentication already enabled"},
+	{service.ErrTwoFactorNotEnabled, http.StatusConflict, "two-factor-not-enabled", "Two-factor authentication not enabled"},
+	{service.ErrInvalidTwoFactor, http.StatusUnprocessableEntity, "invalid-two-factor-code", "Invalid two-factor code"},
+	{service.ErrTwoFactorLocked, http.StatusTooManyRequests, "two-factor-locked", "Too many failed two-factor attempts"},
+	{service.ErrInvalidExternalProfile, http.StatusUnauthorized, "invalid-external-profile", "Invalid external profile"},
+	{service.ErrIdentityLinked, http.StatusConflict, "identity-linked", "External identity linked to another user"},
+	{service.ErrInvalidOrderNumber, http.StatusUnprocessableEntity, "invalid-order-number", "Invalid order number format"},
+	{service.ErrOrderNotFound, http.StatusNotFound, "order-not-found", "Order not found"},
+	{service.ErrOrderOwnedByOther, http.StatusConflict, "order-owned-by-other", "Order already uploaded by another user"},
+	{service.ErrOrderExists, http.StatusConflict, "order-exists", "Order already exists"},
+	{service.ErrEmptyBatch, http.StatusBadRequest, "empty-batch", "Batch contains no orders"},
+	{service.ErrBatchTooLarge, http.StatusRequestEntityTooLarge, "batch-too-large", "Batch contains too many orders"},
+	{service.ErrAccrualUnavailable, http.StatusBadGateway, "accrual-unavailable", "Accrual system unavailable"},
+	{service.ErrInsufficientFunds, http.StatusPaymentRequired, "insufficient-funds", "Insufficient funds"},
+	{service.ErrInvalidCursor, http.StatusBadRequest, "invalid-cursor", "Invalid cursor"},
+	{service.ErrInvalidListParams, http.StatusBadRequest, "invalid-list-parameters", "Invalid list parameters"},
+	{service.ErrIdempotencyInProgress, http.StatusConflict, "idempotency-in-progress", "Idempotency key in use"},
+	{service.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency-key-reused", "Idempotency key reused"},
+	{service.ErrSearchQueryRequired, http.StatusBadRequest, "search-query-required", "Search query is required"},
+	{service.ErrReasonRequired, http.StatusBadRequest, "reason-required", "Reason is required"},
+	{service.ErrZeroAmount, http.StatusBadRequest, "zero-amount", "Amount must not be zero"},
+	{service.ErrInvalidWebhookURL, http.StatusBadRequest, "invalid-webhook-url", "Invalid webhook URL"},
+	{service.ErrWebhookURLNotAllowed, http.StatusBadRequest, "webhook-url-not-allowed", "Webhook URL not allowed"},
+	{service.ErrInvalidEventType, http.StatusBadRequest, "invalid-event-type", "Invalid event type"},
+	{service.ErrWebhookSecretRequired, http.StatusBadRequest, "webhook-secret-required", "Webhook secret is required"},
+	{service.ErrWebhookNotFound, http.StatusNotFound, "webhook-not-found", "Webhook subscription not found"},
+	{service.ErrDeliveryNotFound, http.StatusNotFound, "delivery-not-found", "Webhook delivery not found"},
+	{service.ErrDeliveryNotReplayable, http.StatusConflict, "delivery-not-replayable", "Webhook delivery not replayable"},
+}
+
+// writeError translates a service error into a problem response
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var policyErr *service.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		writeProblemDetails(w, &Problem{
+			Type:       problemTypePrefix + "weak-password",
+			Title:      "Password does not satisfy policy",
+			Status:     http.StatusBadRequest,
+			Instance:   r.URL.Path,
+			Violations: policyErr.Violations,
+		})
+		return
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			writeProblemDetails(w, &Problem{
+				Type:     problemTypePrefix + m.slug,
+				Title:    m.title,
+				Status:   m.status,
+				Instance: r.URL.Path,
+			})
+			return
+		}
+	}
+
+	// Unknown errors are internal, their text is not exposed to clients
+	s.logger.LogAttrs(r.Context(), slog.LevelError, "Request failed", slog.Any("error", err))
+	writeProblem(w, r, http.StatusInternalServerError, "")
+}
+
+// writeProblem writes a generic problem response for the status code
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblemDetails(w, &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// writeProblemDetails writes a problem as application/problem+json
+func writeProblemDetails(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -3,7 +3,13 @@ package http
 import (
 	"encoding/json"
 	"errors"
+	"gophermart/domain/entity"
+	"gophermart/domain/service"
+	"gophermart/internal/auth"
+	"gophermart/internal/logging"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strings"
 )
@@ -14,6 +20,50 @@ type UserCredentials struct {
 	Password string `json:"password"`
 }
 
+// PasswordChangeRequest represents a password change request
+type PasswordChangeRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// PasswordResetRequest represents a request to start the password reset flow
+type PasswordResetRequest struct {
+	Login string `json:"login"`
+}
+
+// PasswordResetConfirmRequest represents a request to complete the password reset flow
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// TwoFactorChallengeResponse is returned by login when a second factor is required
+type TwoFactorChallengeResponse struct {
+	ChallengeToken string `json:"challenge_token"`
+}
+
+// TwoFactorLoginRequest represents the second step of a two-factor login
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+// TwoFactorCodeRequest represents a request carrying a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorEnrollResponse is returned when a user starts two-factor enrollment
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse carries recovery codes shown to the user once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
 // WithdrawalRequest represents a withdrawal request
 type WithdrawalRequest struct {
 	OrderID string  `json:"order"`
@@ -23,89 +73,275 @@ type WithdrawalRequest struct {
 // register handles user registration
 func (s *Server) register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var creds UserCredentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
 		return
 	}
 
 	if creds.Login == "" || creds.Password == "" {
-		http.Error(w, "Login and password are required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Login and password are required")
 		return
 	}
 
 	user, err := s.userService.Register(r.Context(), creds.Login, creds.Password)
 	if err != nil {
-		if err.Error() == "user already exists" {
-			http.Error(w, "User already exists", http.StatusConflict)
-			return
-		}
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
-	// Generate token
-	token, err := generateToken(user.ID)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+	if err := setTokenCookie(w, user); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	// Set token in cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   3600 * 24, // 1 day
-	})
-
 	w.WriteHeader(http.StatusOK)
 }
 
 // login handles user login
 func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var creds UserCredentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
 		return
 	}
 
 	if creds.Login == "" || creds.Password == "" {
-		http.Error(w, "Login and password are required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Login and password are required")
 		return
 	}
 
 	user, err := s.userService.Login(r.Context(), creds.Login, creds.Password)
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		s.writeError(w, r, err)
 		return
 	}
 
-	// Generate token
-	token, err := generateToken(user.ID)
+	startSession(w, r, user)
+}
+
+// startSession logs in a user who passed the first factor.
+// Users with two-factor authentication get a challenge instead of a session.
+func startSession(w http.ResponseWriter, r *http.Request, user *entity.User) {
+	if user.TOTPEnabled {
+		challenge, err := auth.GenerateChallengeToken(user)
+		if err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, TwoFactorChallengeResponse{ChallengeToken: challenge})
+		return
+	}
+
+	if err := setTokenCookie(w, user); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// loginSecondFactor completes a two-factor login
+func (s *Server) loginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if req.ChallengeToken == "" || req.Code == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Challenge token and code are required")
+		return
+	}
+
+	claims, err := auth.ValidateChallengeToken(req.ChallengeToken)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusUnauthorized, "Invalid challenge token")
 		return
 	}
 
-	// Set token in cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   3600 * 24, // 1 day
-	})
+	if _, err := s.userService.ValidateSession(r.Context(), claims.UserID, claims.TokenVersion); err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, "Invalid challenge token")
+		return
+	}
+
+	user, err := s.userService.VerifySecondFactor(r.Context(), claims.UserID, req.Code)
+	if err != nil {
+		// During login a wrong code means the user is not authenticated yet
+		if errors.Is(err, service.ErrInvalidTwoFactor) {
+			writeProblem(w, r, http.StatusUnauthorized, "Invalid two-factor code")
+			return
+		}
+		s.writeError(w, r, err)
+		return
+	}
+
+	if err := setTokenCookie(w, user); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// enrollTwoFactor starts two-factor enrollment for an authenticated user
+func (s *Server) enrollTwoFactor(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	secret, uri, err := s.userService.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TwoFactorEnrollResponse{Secret: secret, URI: uri})
+}
+
+// verifyTwoFactor confirms enrollment and enables two-factor authentication
+func (s *Server) verifyTwoFactor(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	codes, err := s.userService.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// disableTwoFactor turns off two-factor authentication
+func (s *Server) disableTwoFactor(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	err := s.userService.DisableTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// changePassword handles password change for an authenticated user
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req PasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if req.OldPassword == "" || req.NewPassword == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Old and new passwords are required")
+		return
+	}
+
+	user, err := s.userService.ChangePassword(r.Context(), userID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// Other sessions are revoked, reissue the token for the current one
+	if err := setTokenCookie(w, user); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// requestPasswordReset starts the password reset flow
+func (s *Server) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if req.Login == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Login is required")
+		return
+	}
+
+	if err := s.userService.RequestPasswordReset(r.Context(), req.Login); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// Respond the same way whether or not the login exists
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// confirmPasswordReset completes the password reset flow
+func (s *Server) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Token and new password are required")
+		return
+	}
+
+	err := s.userService.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -118,15 +354,20 @@ func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request, userID int
 	case http.MethodPost:
 		s.uploadOrder(w, r, userID)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 	}
 }
 
-// getOrders retrieves all orders for a user
+// getOrders retrieves orders for a user, all of them or a page when query parameters are given
 func (s *Server) getOrders(w http.ResponseWriter, r *http.Request, userID int64) {
+	if isPaginated(r) {
+		s.getOrdersPage(w, r, userID)
+		return
+	}
+
 	orders, err := s.orderService.GetUserOrders(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Failed to get orders", http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
@@ -137,53 +378,123 @@ func (s *Server) getOrders(w http.ResponseWriter, r *http.Request, userID int64)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(orders); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+}
+
+// getOrdersPage retrieves a page of orders for a user
+func (s *Server) getOrdersPage(w http.ResponseWriter, r *http.Request, userID int64) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+
+	page, err := s.orderService.ListUserOrders(r.Context(), userID, params)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	setPageHeaders(w, r, page.NextCursor)
+
+	if len(page.Orders) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page.Orders)
 }
 
 // uploadOrder uploads a new order
 func (s *Server) uploadOrder(w http.ResponseWriter, r *http.Request, userID int64) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
 
 	orderID := strings.TrimSpace(string(body))
 	if orderID == "" {
-		http.Error(w, "Order ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Order ID is required")
 		return
 	}
+	r = r.WithContext(logging.WithAttrs(r.Context(), slog.String("order_id", orderID)))
 
 	_, err = s.orderService.UploadOrder(r.Context(), orderID, userID)
 	if err != nil {
-		switch {
-		case err.Error() == "invalid order number":
-			http.Error(w, "Invalid order number format", http.StatusUnprocessableEntity)
-		case err.Error() == "order already uploaded by another user":
-			http.Error(w, "Order already uploaded by another user", http.StatusConflict)
-		case err.Error() == "order already uploaded by you":
+		if errors.Is(err, service.ErrOrderAlreadyUploaded) {
 			w.WriteHeader(http.StatusOK)
-		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
+		s.writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// uploadOrders handles a batch upload of orders given as a JSON array or as newline-delimited text
+func (s *Server) uploadOrders(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderIDs, err := parseOrderBatch(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	results, err := s.orderService.UploadOrders(r.Context(), orderIDs, userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// parseOrderBatch reads order numbers from a JSON array or from newline-delimited text, skipping blank lines.
+// Blank entries of a JSON array are kept, so that they are reported as invalid at their position.
+func parseOrderBatch(r *http.Request) ([]string, error) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var orderIDs []string
+		if err := json.NewDecoder(r.Body).Decode(&orderIDs); err != nil {
+			return nil, err
+		}
+		for i := range orderIDs {
+			orderIDs[i] = strings.TrimSpace(orderIDs[i])
+		}
+		return orderIDs, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var orderIDs []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if orderID := strings.TrimSpace(line); orderID != "" {
+			orderIDs = append(orderIDs, orderID)
+		}
+	}
+	return orderIDs, nil
+}
+
 // getBalance retrieves a user's balance
 func (s *Server) getBalance(w http.ResponseWriter, r *http.Request, userID int64) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	balance, err := s.balanceService.GetUserBalance(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Failed to get balance", http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
@@ -197,7 +508,7 @@ func (s *Server) getBalance(w http.ResponseWriter, r *http.Request, userID int64
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to encode response")
 		return
 	}
 }
@@ -205,49 +516,46 @@ func (s *Server) getBalance(w http.ResponseWriter, r *http.Request, userID int64
 // withdraw handles point withdrawal
 func (s *Server) withdraw(w http.ResponseWriter, r *http.Request, userID int64) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req WithdrawalRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
 		return
 	}
 
 	if req.OrderID == "" || req.Sum <= 0 {
-		http.Error(w, "Invalid withdrawal request", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Invalid withdrawal request")
 		return
 	}
+	r = r.WithContext(logging.WithAttrs(r.Context(), slog.String("order_id", req.OrderID)))
 
 	err := s.balanceService.WithdrawPoints(r.Context(), userID, req.OrderID, req.Sum)
 	if err != nil {
-		switch {
-		case err.Error() == "invalid order number":
-			http.Error(w, "Invalid order number format", http.StatusUnprocessableEntity)
-		case err.Error() == "order already exists":
-			http.Error(w, "Order already exists", http.StatusConflict)
-		case err.Error() == "insufficient funds":
-			http.Error(w, "Insufficient funds", http.StatusPaymentRequired)
-		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+		s.writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-// getWithdrawals retrieves all withdrawals for a user
+// getWithdrawals retrieves withdrawals for a user, all of them or a page when query parameters are given
 func (s *Server) getWithdrawals(w http.ResponseWriter, r *http.Request, userID int64) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if isPaginated(r) {
+		s.getWithdrawalsPage(w, r, userID)
 		return
 	}
 
 	withdrawals, err := s.balanceService.GetUserWithdrawals(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Failed to get withdrawals", http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
@@ -258,30 +566,117 @@ func (s *Server) getWithdrawals(w http.ResponseWriter, r *http.Request, userID i
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(withdrawals); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to encode response")
 		return
 	}
 }
 
+// getWithdrawalsPage retrieves a page of withdrawals for a user
+func (s *Server) getWithdrawalsPage(w http.ResponseWriter, r *http.Request, userID int64) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.balanceService.ListUserWithdrawals(r.Context(), userID, params)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	setPageHeaders(w, r, page.NextCursor)
+
+	if len(page.Withdrawals) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page.Withdrawals)
+}
+
 // withAuth is a middleware to authenticate requests
 func (s *Server) withAuth(handler func(http.ResponseWriter, *http.Request, int64)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("token")
-		if err != nil {
-			if errors.Is(err, http.ErrNoCookie) {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			http.Error(w, "Bad request", http.StatusBadRequest)
+		claims, ok := s.authenticate(w, r)
+		if !ok {
 			return
 		}
 
-		userID, err := validateToken(cookie.Value)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		handler(w, withUserID(r, claims.UserID), claims.UserID)
+	}
+}
+
+// withRole is a middleware to authenticate requests and require one of the given roles
+func (s *Server) withRole(handler func(http.ResponseWriter, *http.Request, int64), roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := s.authenticate(w, r)
+		if !ok {
 			return
 		}
 
-		handler(w, r, userID)
+		r = withUserID(r, claims.UserID)
+		for _, role := range roles {
+			if claims.Role == role {
+				handler(w, r, claims.UserID)
+				return
+			}
+		}
+
+		writeProblem(w, r, http.StatusForbidden, "Forbidden")
 	}
 }
+
+// authenticate validates the session cookie and writes an error response if it is not valid
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	cookie, err := r.Cookie("token")
+	if err != nil {
+		if errors.Is(err, http.ErrNoCookie) {
+			writeProblem(w, r, http.StatusUnauthorized, "Unauthorized")
+			return nil, false
+		}
+		writeProblem(w, r, http.StatusBadRequest, "Bad request")
+		return nil, false
+	}
+
+	claims, err := auth.Authenticate(r.Context(), s.userService, cookie.Value)
+	if err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// withUserID adds the authenticated user to the log records and the access log entry of the request
+func withUserID(r *http.Request, userID int64) *http.Request {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID
+	}
+	return r.WithContext(logging.WithAttrs(r.Context(), slog.Int64("user_id", userID)))
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// setTokenCookie generates a token for the user and sets it in a cookie
+func setTokenCookie(w http.ResponseWriter, user *entity.User) error {
+	token, err := auth.GenerateToken(user)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   int(auth.SessionTTL.Seconds()),
+	})
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"gophermart/internal/health"
+	"net/http"
+)
+
+// getLiveness reports that the process is up and serving HTTP
+func (s *Server) getLiveness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// getReadiness runs the readiness checks, answering 503 while a required check fails or shutdown is under way
+func (s *Server) getReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	report := s.health.Ready(r.Context())
+	for name, result := range report.Checks {
+		if result.Status == health.StatusFail {
+			s.logger.WarnContext(r.Context(), "Readiness check failed", "check", name, "error", result.Error)
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gophermart/domain/entity"
+	"io"
+	"net/http"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotencyReplayHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20 // bodies are buffered in memory to fingerprint the request
+)
+
+// recordingResponseWriter passes a response through while keeping a copy of it
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (rw *recordingResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// withIdempotency is a middleware that honors the Idempotency-Key header on POST requests.
+// The first response for a user and key is stored and replayed to retries of the same request.
+func (s *Server) withIdempotency(handler func(http.ResponseWriter, *http.Request, int64)) func(http.ResponseWriter, *http.Request, int64) {
+	return func(w http.ResponseWriter, r *http.Request, userID int64) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			handler(w, r, userID)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			writeProblem(w, r, http.StatusBadRequest, "Idempotency key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeProblem(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+				return
+			}
+			writeProblem(w, r, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, err := s.idempotencyService.Begin(r.Context(), userID, key, requestFingerprint(r, body))
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+
+		if record != nil {
+			replayResponse(w, record)
+			return
+		}
+
+		// The key is released unless the response gets stored, also when the request is cancelled
+		// or the handler panics. The panic is not recovered here and goes on to the recovery middleware.
+		ctx := context.WithoutCancel(r.Context())
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := s.idempotencyService.Release(ctx, userID, key); err != nil {
+				s.logger.ErrorContext(ctx, "Failed to release idempotency key", "error", err)
+			}
+		}()
+
+		rw := &recordingResponseWriter{ResponseWriter: w}
+		handler(rw, r, userID)
+
+		// Server errors are not stored so that the client can retry them
+		if rw.status == 0 || rw.status >= http.StatusInternalServerError {
+			return
+		}
+
+		record = &entity.IdempotencyRecord{
+			UserID:     userID,
+			Key:        key,
+			StatusCode: rw.status,
+			Headers:    storedHeaders(w.Header()),
+			Body:       rw.body.Bytes(),
+		}
+		if err := s.idempotencyService.Complete(ctx, record); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// replayResponse writes a stored response
+func replayResponse(w http.ResponseWriter, record *entity.IdempotencyRecord) {
+	for name, values := range record.Headers {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.Header().Set(idempotencyReplayHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	_, _ = w.Write(record.Body)
+}
+
+// storedHeaders returns the response headers worth replaying, cookies are never stored
+func storedHeaders(h http.Header) map[string][]string {
+	stored := make(map[string][]string)
+	for name, values := range h {
+		if name == "Set-Cookie" {
+			continue
+		}
+		stored[name] = values
+	}
+	return stored
+}
+
+// requestFingerprint identifies a request by method, path and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"gophermart/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// idempotentRequest sends a POST with an Idempotency-Key straight to an idempotent handler
+func idempotentRequest(
+	ctx context.Context,
+	handler func(http.ResponseWriter, *http.Request, int64),
+	userID int64,
+	key, body string,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set(idempotencyKeyHeader, key)
+
+	rec := httptest.NewRecorder()
+	handler(rec, req, userID)
+
+	return rec
+}
+
+// registeredUserID registers a user and returns its ID
+func registeredUserID(t *testing.T, ts *testServer, login string) int64 {
+	t.Helper()
+
+	claims, err := auth.ValidateToken(ts.register(t, login).Value)
+	if err != nil {
+		t.Fatalf("invalid session token: %v", err)
+	}
+
+	return claims.UserID
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	ts := newTestServer(t, nil)
+	userID := registeredUserID(t, ts, "alice")
+
+	calls := 0
+	handler := ts.withIdempotency(func(w http.ResponseWriter, _ *http.Request, _ int64) {
+		calls++
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for i := 0; i < 2; i++ {
+		if rec := idempotentRequest(context.Background(), handler, userID, "key", "12345678903"); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d answered %d, want %d", i+1, rec.Code, http.StatusAccepted)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+
+	rec := idempotentRequest(context.Background(), handler, userID, "key", "79927398713")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("request with another body answered %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterPanic(t *testing.T) {
+	ts := newTestServer(t, nil)
+	userID := registeredUserID(t, ts, "alice")
+
+	calls := 0
+	handler := ts.withIdempotency(func(w http.ResponseWriter, _ *http.Request, _ int64) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic did not reach the caller")
+			}
+		}()
+		idempotentRequest(context.Background(), handler, userID, "key", "12345678903")
+	}()
+
+	if rec := idempotentRequest(context.Background(), handler, userID, "key", "12345678903"); rec.Code != http.StatusAccepted {
+		t.Errorf("retry after a panic answered %d, want %d", rec.Code, http.StatusAccepted)
+	}
+}
+
+func TestIdempotencyReleasesKeyOfCancelledRequest(t *testing.T) {
+	ts := newTestServer(t, nil)
+	userID := registeredUserID(t, ts, "alice")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	handler := ts.withIdempotency(func(w http.ResponseWriter, _ *http.Request, _ int64) {
+		calls++
+		if calls == 1 {
+			// The client goes away while the request is handled
+			cancel()
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	idempotentRequest(ctx, handler, userID, "key", "12345678903")
+
+	if rec := idempotentRequest(context.Background(), handler, userID, "key", "12345678903"); rec.Code != http.StatusAccepted {
+		t.Errorf("retry after a cancelled request answered %d, want %d", rec.Code, http.StatusAccepted)
+	}
+}
+
+func TestIdempotencyLimitsBody(t *testing.T) {
+	ts := newTestServer(t, nil)
+	userID := registeredUserID(t, ts, "alice")
+
+	handler := ts.withIdempotency(func(w http.ResponseWriter, _ *http.Request, _ int64) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	body := strings.Repeat("1", maxIdempotentBodySize+1)
+	if rec := idempotentRequest(context.Background(), handler, userID, "key", body); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("request with a large body answered %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"gophermart/internal/logging"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("gophermart/internal/http")
+
+// middleware wraps a handler with cross-cutting behavior
+type middleware func(http.Handler) http.Handler
+
+// chain wraps a handler with middlewares, the first one being the outermost
+func chain(handler http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// requestInfoKey is the context key of the requestInfo filled in while the request is handled
+type requestInfoKey struct{}
+
+// requestInfo collects details for the access log that are only known deeper in the handler chain
+type requestInfo struct {
+	userID int64
+}
+
+// statusResponseWriter records the status code and the number of bytes written
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status code
+func (rw *statusResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes of the body
+func (rw *statusResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// statusCode returns the recorded status code, a handler that writes nothing responds with 200
+func (rw *statusResponseWriter) statusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Unwrap lets http.ResponseController reach the flusher and deadlines of the underlying writer
+func (rw *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack takes over the connection for WebSocket upgrades, which need an http.Hijacker
+func (rw *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil && rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// withRequestID propagates the X-Request-ID of the request or generates a new one,
+// echoes it in the response and adds it to the context for downstream logs
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := logging.AcceptRequestID(r.Header.Get(logging.RequestIDHeader))
+
+		w.Header().Set(logging.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// withAccessLog logs every request with its status, size, latency and authenticated user
+func (s *Server) withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+		ctx = logging.WithAttrs(ctx, slog.String("method", r.Method), slog.String("path", r.URL.Path))
+		rw := &statusResponseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		attrs := []slog.Attr{
+			slog.Int("status", rw.statusCode()),
+			slog.Int("bytes", rw.bytes),
+			slog.Duration("latency", time.Since(start)),
+		}
+		if info.userID != 0 {
+			attrs = append(attrs, slog.Int64("user_id", info.userID))
+		}
+		s.logger.LogAttrs(ctx, slog.LevelInfo, "Request handled", attrs...)
+	})
+}
+
+// withTracing continues the trace propagated by the client or starts a new one, with a server span per request
+func withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = logging.WithAttrs(ctx, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		rw, ok := w.(*statusResponseWriter)
+		if !ok {
+			rw = &statusResponseWriter{ResponseWriter: w}
+		}
+
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rw, r)
+
+		// The route pattern is only known once the mux has matched the request
+		if r.Pattern != "" {
+			span.SetName(r.Method + " " + r.Pattern)
+			span.SetAttributes(attribute.String("http.route", r.Pattern))
+		}
+		status := rw.statusCode()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// withMetrics counts requests and their latency by route pattern, method and status
+func (s *Server) withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw, ok := w.(*statusResponseWriter)
+		if !ok {
+			rw = &statusResponseWriter{ResponseWriter: w}
+		}
+
+		next.ServeHTTP(rw, r)
+
+		// The mux sets the matched pattern on the request, using it keeps the number of label values bounded
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		s.metrics.ObserveHTTPRequest(route, r.Method, rw.statusCode(), time.Since(start))
+	})
+}
+
+// withRecovery turns a panic in a handler into a 500 response instead of dropping the connection
+func (s *Server) withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw, ok := w.(*statusResponseWriter)
+		if !ok {
+			rw = &statusResponseWriter{ResponseWriter: w}
+		}
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// The server aborts the response on purpose with this panic
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			s.logger.ErrorContext(r.Context(), "Handler panicked", "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+
+			// Headers already sent cannot be replaced, the client sees a truncated response
+			if rw.status == 0 {
+				writeProblem(rw, r, http.StatusInternalServerError, "")
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
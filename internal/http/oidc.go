@@ -0,0 +1,189 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gophermart/domain/service"
+	"gophermart/internal/auth"
+	"gophermart/internal/oidc"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	oidcStateCookie   = "oidc_state"
+	oidcStateTTL      = 10 * time.Minute
+	audienceOIDCState = "gophermart-oidc"
+)
+
+// oidcStateClaims carries the authorization request state between login and callback
+type oidcStateClaims struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserID   int64  `json:"link_user_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// oidcLogin redirects the user to the identity provider.
+// If the request carries a valid session, the external identity is linked to that user.
+func (s *Server) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	claims := &oidcStateClaims{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oidc.GenerateVerifier(),
+		LinkUserID:   s.sessionUserID(r),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{audienceOIDCState},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcStateTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	signed, err := auth.SignClaims(claims)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    signed,
+		Path:     "/api/user/oidc",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(oidcStateTTL.Seconds()),
+	})
+
+	http.Redirect(w, r, s.oidcProvider.AuthCodeURL(state, nonce, claims.CodeVerifier), http.StatusFound)
+}
+
+// oidcCallback completes the authorization code flow and logs the user in
+func (s *Server) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		writeProblem(w, r, http.StatusUnauthorized, "Identity provider error: "+errParam)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Missing login state")
+		return
+	}
+
+	// The state cookie is single-use
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/user/oidc", MaxAge: -1})
+
+	claims, err := parseOIDCState(cookie.Value)
+	if err != nil || claims.State != r.URL.Query().Get("state") {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid login state")
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Authorization code is required")
+		return
+	}
+
+	identity, err := s.oidcProvider.Exchange(r.Context(), code, claims.CodeVerifier, claims.Nonce)
+	if err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	login := identity.PreferredUsername
+	if login == "" {
+		login = identity.Email
+	}
+
+	profile := service.ExternalProfile{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		Login:   login,
+	}
+
+	user, err := s.identityService.Login(r.Context(), profile, claims.LinkUserID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// Linking happens within a session that already passed the second factor
+	if claims.LinkUserID != 0 {
+		if err := setTokenCookie(w, user); err != nil {
+			writeProblem(w, r, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The identity provider only replaces the password, a second factor is still required
+	startSession(w, r, user)
+}
+
+// sessionUserID returns the ID of the user with a valid session cookie, or zero
+func (s *Server) sessionUserID(r *http.Request) int64 {
+	cookie, err := r.Cookie("token")
+	if err != nil {
+		return 0
+	}
+
+	claims, err := auth.Authenticate(r.Context(), s.userService, cookie.Value)
+	if err != nil {
+		return 0
+	}
+
+	return claims.UserID
+}
+
+// parseOIDCState validates the signed login state cookie
+func parseOIDCState(tokenString string) (*oidcStateClaims, error) {
+	claims := &oidcStateClaims{}
+	if err := auth.ParseClaims(tokenString, claims); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	if !claims.VerifyAudience(audienceOIDCState, true) {
+		return nil, errors.New("invalid state")
+	}
+
+	return claims, nil
+}
+
+// randomString returns a random URL-safe string
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
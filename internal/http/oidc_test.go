@@ -0,0 +1,231 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // TOTP uses HMAC-SHA1
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"gophermart/internal/auth"
+	"gophermart/internal/oidc"
+	"gophermart/internal/oidc/oidctest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newOIDCTestServer creates a test server that logs in through a stub identity provider
+func newOIDCTestServer(t *testing.T) (*testServer, *oidctest.IdP) {
+	t.Helper()
+
+	idp := oidctest.NewIdP(t)
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:       idp.URL,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://gophermart.test/api/user/oidc/callback",
+	})
+	if err != nil {
+		t.Fatalf("failed to discover the identity provider: %v", err)
+	}
+
+	return newTestServer(t, provider), idp
+}
+
+// startOIDCLogin starts a login and lets the identity provider authorize it.
+// It returns the state cookie and the callback URL the user is redirected to.
+func startOIDCLogin(t *testing.T, ts *testServer, idp *oidctest.IdP, cookies ...*http.Cookie) (*http.Cookie, *url.URL) {
+	t.Helper()
+
+	rec := ts.do(t, http.MethodGet, "/api/user/oidc/login", nil, cookies...)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login answered %d, want %d: %s", rec.Code, http.StatusFound, rec.Body)
+	}
+
+	return cookieNamed(t, rec, oidcStateCookie), idp.Authorize(t, rec.Header().Get("Location"))
+}
+
+// finishOIDCLogin follows the redirect back to the callback endpoint
+func finishOIDCLogin(t *testing.T, ts *testServer, callback *url.URL, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return ts.do(t, http.MethodGet, callback.RequestURI(), nil, cookies...)
+}
+
+// sessionUser returns the ID of the user logged in by the response
+func sessionUser(t *testing.T, rec *httptest.ResponseRecorder) int64 {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback answered %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	claims, err := auth.ValidateToken(cookieNamed(t, rec, "token").Value)
+	if err != nil {
+		t.Fatalf("invalid session token: %v", err)
+	}
+
+	return claims.UserID
+}
+
+func TestOIDCFirstLoginProvisionsUser(t *testing.T) {
+	ts, idp := newOIDCTestServer(t)
+	idp.SetAccount(oidctest.Account{Subject: "42", PreferredUsername: "alice"})
+
+	state, callback := startOIDCLogin(t, ts, idp)
+	userID := sessionUser(t, finishOIDCLogin(t, ts, callback, state))
+
+	// The provisioned user took the preferred username
+	rec := ts.do(t, http.MethodPost, "/api/user/register", UserCredentials{Login: "alice", Password: "Passw0rd!"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("register alice answered %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// The next login finds the identity instead of provisioning another user
+	state, callback = startOIDCLogin(t, ts, idp)
+	if got := sessionUser(t, finishOIDCLogin(t, ts, callback, state)); got != userID {
+		t.Errorf("second login logged in user %d, want %d", got, userID)
+	}
+}
+
+func TestOIDCLinksExistingUser(t *testing.T) {
+	ts, idp := newOIDCTestServer(t)
+	idp.SetAccount(oidctest.Account{Subject: "42", PreferredUsername: "bob"})
+
+	session := ts.register(t, "bob")
+	claims, err := auth.ValidateToken(session.Value)
+	if err != nil {
+		t.Fatalf("invalid session token: %v", err)
+	}
+
+	state, callback := startOIDCLogin(t, ts, idp, session)
+	if got := sessionUser(t, finishOIDCLogin(t, ts, callback, state)); got != claims.UserID {
+		t.Fatalf("linking logged in user %d, want %d", got, claims.UserID)
+	}
+
+	// Without a session the linked user is logged in
+	state, callback = startOIDCLogin(t, ts, idp)
+	if got := sessionUser(t, finishOIDCLogin(t, ts, callback, state)); got != claims.UserID {
+		t.Errorf("login logged in user %d, want the linked user %d", got, claims.UserID)
+	}
+
+	// The identity cannot be linked to another user
+	other := ts.register(t, "carol")
+	state, callback = startOIDCLogin(t, ts, idp, other)
+	if rec := finishOIDCLogin(t, ts, callback, state); rec.Code != http.StatusConflict {
+		t.Errorf("linking a linked identity answered %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestOIDCStateMismatch(t *testing.T) {
+	ts, idp := newOIDCTestServer(t)
+
+	state, callback := startOIDCLogin(t, ts, idp)
+
+	tampered := *callback
+	query := tampered.Query()
+	query.Set("state", "forged")
+	tampered.RawQuery = query.Encode()
+
+	if rec := finishOIDCLogin(t, ts, &tampered, state); rec.Code != http.StatusBadRequest {
+		t.Errorf("callback with another state answered %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := finishOIDCLogin(t, ts, callback); rec.Code != http.StatusBadRequest {
+		t.Errorf("callback without the state cookie answered %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCNonceMismatch(t *testing.T) {
+	ts, idp := newOIDCTestServer(t)
+	idp.SetNonce("replayed-nonce")
+
+	state, callback := startOIDCLogin(t, ts, idp)
+	if rec := finishOIDCLogin(t, ts, callback, state); rec.Code != http.StatusUnauthorized {
+		t.Errorf("callback with an ID token for another nonce answered %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCCodeBoundToVerifier(t *testing.T) {
+	ts, idp := newOIDCTestServer(t)
+
+	// A code injected into another login is exchanged with that login's PKCE verifier
+	_, victim := startOIDCLogin(t, ts, idp)
+	attackerState, attacker := startOIDCLogin(t, ts, idp)
+
+	injected := *attacker
+	query := injected.Query()
+	query.Set("code", victim.Query().Get("code"))
+	injected.RawQuery = query.Encode()
+
+	if rec := finishOIDCLogin(t, ts, &injected, attackerState); rec.Code != http.StatusUnauthorized {
+		t.Errorf("callback with a code of another login answered %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCRequiresSecondFactor(t *testing.T) {
+	ts, idp := newOIDCTestServer(t)
+	idp.SetAccount(oidctest.Account{Subject: "42", PreferredUsername: "dave"})
+
+	session := ts.register(t, "dave")
+
+	// Link the identity first, linking happens within a full session
+	state, callback := startOIDCLogin(t, ts, idp, session)
+	sessionUser(t, finishOIDCLogin(t, ts, callback, state))
+
+	rec := ts.do(t, http.MethodPost, "/api/user/2fa/enroll", nil, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enroll answered %d: %s", rec.Code, rec.Body)
+	}
+	var enrollment TwoFactorEnrollResponse
+	if err := json.NewDecoder(rec.Body).Decode(&enrollment); err != nil {
+		t.Fatalf("failed to decode enrollment: %v", err)
+	}
+
+	rec = ts.do(t, http.MethodPost, "/api/user/2fa/verify", TwoFactorCodeRequest{Code: totpCode(t, enrollment.Secret)}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify answered %d: %s", rec.Code, rec.Body)
+	}
+
+	state, callback = startOIDCLogin(t, ts, idp)
+	rec = finishOIDCLogin(t, ts, callback, state)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("login of a user with two-factor authentication answered %d, want %d", rec.Code, http.StatusAccepted)
+	}
+
+	var challenge TwoFactorChallengeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&challenge); err != nil || challenge.ChallengeToken == "" {
+		t.Fatalf("response has no challenge token: %v", err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "token" {
+			t.Error("login set a session cookie before the second factor")
+		}
+	}
+}
+
+// totpCode computes the current RFC 6238 code for a secret
+func totpCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatalf("invalid TOTP secret: %v", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000)
+}
@@ -0,0 +1,22 @@
+package http
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 description of every route registered in NewServer
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// getOpenAPISpec serves the OpenAPI document
+func (s *Server) getOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
@@ -0,0 +1,408 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gophermart/internal/oidc/oidctest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// registerStreamDecoder lets the validator read event stream bodies, which it does not know
+var registerStreamDecoder sync.Once
+
+// loadOpenAPISpec parses and validates the embedded OpenAPI document
+func loadOpenAPISpec(t *testing.T) *openapi3.T {
+	t.Helper()
+
+	registerStreamDecoder.Do(func() {
+		openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.FileBodyDecoder)
+	})
+
+	spec, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatalf("failed to load OpenAPI document: %v", err)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+
+	return spec
+}
+
+// apiCall is a request sent to the server whose response is checked against the OpenAPI document
+type apiCall struct {
+	method  string
+	path    string // Path template in the OpenAPI document
+	target  string // Request URI, the path template when empty
+	body    any    // Sent as text/plain when it is a string and as JSON otherwise
+	cookies []*http.Cookie
+	header  http.Header
+	stream  bool // The response is a stream, only its headers are checked
+	status  int
+}
+
+// specChecker sends requests to a running server and validates the responses against the OpenAPI document
+type specChecker struct {
+	spec    *openapi3.T
+	server  *httptest.Server
+	client  *http.Client
+	covered map[string]bool
+}
+
+// newSpecChecker starts the server of ts
+func newSpecChecker(t *testing.T, ts *testServer) *specChecker {
+	t.Helper()
+
+	server := httptest.NewServer(ts.handler)
+	t.Cleanup(server.Close)
+
+	return &specChecker{
+		spec:   loadOpenAPISpec(t),
+		server: server,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		covered: make(map[string]bool),
+	}
+}
+
+// check sends the call and validates the response.
+// The body of the returned response can be read again unless it is a stream.
+func (c *specChecker) check(t *testing.T, call apiCall) *http.Response {
+	t.Helper()
+
+	target := call.target
+	if target == "" {
+		target = call.path
+	}
+	name := call.method + " " + target
+
+	var body io.Reader
+	contentType := ""
+	switch b := call.body.(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+		contentType = "text/plain"
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("%s: failed to encode request body: %v", name, err)
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, call.method, c.server.URL+target, body)
+	if err != nil {
+		t.Fatalf("%s: failed to create request: %v", name, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, values := range call.header {
+		req.Header[key] = values
+	}
+	for _, cookie := range call.cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		t.Fatalf("%s: request failed: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	var respBody []byte
+	if !call.stream {
+		if respBody, err = io.ReadAll(resp.Body); err != nil {
+			t.Fatalf("%s: failed to read response: %v", name, err)
+		}
+	}
+
+	if resp.StatusCode != call.status {
+		t.Errorf("%s answered %d, want %d: %s", name, resp.StatusCode, call.status, respBody)
+	}
+
+	pathItem := c.spec.Paths.Value(call.path)
+	if pathItem == nil || pathItem.GetOperation(call.method) == nil {
+		t.Errorf("%s: the OpenAPI document has no operation %s %s", name, call.method, call.path)
+		return resp
+	}
+	c.covered[call.method+" "+call.path] = true
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request: req,
+			Route: &routers.Route{
+				Spec:      c.spec,
+				Path:      call.path,
+				PathItem:  pathItem,
+				Method:    call.method,
+				Operation: pathItem.GetOperation(call.method),
+			},
+		},
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Options: &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true},
+	}
+	input.SetBodyBytes(respBody)
+
+	if err := openapi3filter.ValidateResponse(ctx, input); err != nil {
+		t.Errorf("%s: response %d does not match the OpenAPI document: %v", name, resp.StatusCode, err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp
+}
+
+// login logs in through the API and returns the session cookie
+func (c *specChecker) login(t *testing.T, login string) *http.Cookie {
+	t.Helper()
+
+	resp := c.check(t, apiCall{
+		method: http.MethodPost,
+		path:   "/api/user/login",
+		body:   UserCredentials{Login: login, Password: "Passw0rd!"},
+		status: http.StatusOK,
+	})
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "token" {
+			return cookie
+		}
+	}
+
+	t.Fatalf("login %q set no session cookie", login)
+	return nil
+}
+
+// decode decodes a JSON response body
+func decode[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	return v
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	ts, _ := newOIDCTestServer(t)
+	spec := loadOpenAPISpec(t)
+
+	routes := make(map[string]bool)
+	for _, pattern := range ts.routes {
+		routes[pattern] = true
+		if spec.Paths.Value(pattern) == nil {
+			t.Errorf("route %s is not described in the OpenAPI document", pattern)
+		}
+	}
+
+	for path := range spec.Paths.Map() {
+		if !routes[path] {
+			t.Errorf("the OpenAPI document describes %s, which is not registered", path)
+		}
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	ts, idp := newOIDCTestServer(t)
+	c := newSpecChecker(t, ts)
+	ctx := context.Background()
+
+	// Meta endpoints
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/openapi.json", status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/metrics", status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/healthz", status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/readyz", status: http.StatusOK})
+
+	// Registration and login
+	alice := ts.register(t, "alice")
+	bobID := registeredUserID(t, ts, "bob")
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/register",
+		body: UserCredentials{Login: "carol", Password: "Passw0rd!"}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/register",
+		body: UserCredentials{Login: "alice", Password: "Passw0rd!"}, status: http.StatusConflict})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/register",
+		body: map[string]int{"login": 1}, status: http.StatusBadRequest})
+	c.login(t, "alice")
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/login",
+		body: UserCredentials{Login: "alice", Password: "wrong"}, status: http.StatusUnauthorized})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/login/2fa",
+		body: TwoFactorLoginRequest{ChallengeToken: "forged", Code: "123456"}, status: http.StatusUnauthorized})
+
+	// Two-factor authentication
+	dave := ts.register(t, "dave")
+	enrollment := decode[TwoFactorEnrollResponse](t, c.check(t, apiCall{method: http.MethodPost, path: "/api/user/2fa/enroll",
+		cookies: []*http.Cookie{dave}, status: http.StatusOK}))
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/2fa/verify",
+		body: TwoFactorCodeRequest{Code: "000000"}, cookies: []*http.Cookie{dave}, status: http.StatusUnprocessableEntity})
+	recovery := decode[RecoveryCodesResponse](t, c.check(t, apiCall{method: http.MethodPost, path: "/api/user/2fa/verify",
+		body: TwoFactorCodeRequest{Code: totpCode(t, enrollment.Secret)}, cookies: []*http.Cookie{dave}, status: http.StatusOK}))
+	challenge := decode[TwoFactorChallengeResponse](t, c.check(t, apiCall{method: http.MethodPost, path: "/api/user/login",
+		body: UserCredentials{Login: "dave", Password: "Passw0rd!"}, status: http.StatusAccepted}))
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/login/2fa",
+		body: TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: recovery.RecoveryCodes[0]}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/2fa/disable",
+		body: TwoFactorCodeRequest{Code: recovery.RecoveryCodes[1]}, cookies: []*http.Cookie{dave}, status: http.StatusOK})
+
+	// Passwords
+	carol := c.login(t, "carol")
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/password",
+		body: PasswordChangeRequest{OldPassword: "Passw0rd!", NewPassword: "Passw0rd!2"}, cookies: []*http.Cookie{ts.register(t, "frank")}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/password",
+		body: PasswordChangeRequest{OldPassword: "Passw0rd!", NewPassword: "Passw0rd!2"}, status: http.StatusUnauthorized})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/password/reset",
+		body: PasswordResetRequest{Login: "alice"}, status: http.StatusAccepted})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/password/reset/confirm",
+		body: PasswordResetConfirmRequest{Token: "forged", NewPassword: "Passw0rd!2"}, status: http.StatusBadRequest})
+
+	// OpenID Connect
+	idp.SetAccount(oidctest.Account{Subject: "42", PreferredUsername: "erin"})
+	resp := c.check(t, apiCall{method: http.MethodGet, path: "/api/user/oidc/login", status: http.StatusFound})
+	var state *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == oidcStateCookie {
+			state = cookie
+		}
+	}
+	if state == nil {
+		t.Fatal("OIDC login set no state cookie")
+	}
+	callback := idp.Authorize(t, resp.Header.Get("Location"))
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/oidc/callback",
+		target: callback.RequestURI(), cookies: []*http.Cookie{state}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/oidc/callback",
+		target: callback.RequestURI(), status: http.StatusBadRequest})
+
+	// Orders
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/orders",
+		body: "79927398713", cookies: []*http.Cookie{alice}, status: http.StatusAccepted})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/orders",
+		body: "79927398713", cookies: []*http.Cookie{alice}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/orders",
+		body: "79927398710", cookies: []*http.Cookie{alice}, status: http.StatusUnprocessableEntity})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/orders",
+		body: "79927398713", cookies: []*http.Cookie{carol}, status: http.StatusConflict})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/orders/batch",
+		body: []string{"12345678903", "", "79927398713"}, cookies: []*http.Cookie{alice}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/orders", cookies: []*http.Cookie{alice}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/orders",
+		target: "/api/user/orders?limit=1&sort=asc", cookies: []*http.Cookie{alice}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/orders",
+		target: "/api/user/orders?limit=-1", cookies: []*http.Cookie{alice}, status: http.StatusBadRequest})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/orders", cookies: []*http.Cookie{carol}, status: http.StatusNoContent})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/orders", status: http.StatusUnauthorized})
+
+	// Balance
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/balance", cookies: []*http.Cookie{alice}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/balance/withdraw",
+		body: WithdrawalRequest{OrderID: "2377225624", Sum: 10}, cookies: []*http.Cookie{alice}, status: http.StatusPaymentRequired})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/withdrawals", cookies: []*http.Cookie{alice}, status: http.StatusNoContent})
+
+	// Event streams
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/events",
+		cookies: []*http.Cookie{alice}, stream: true, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/ws", cookies: []*http.Cookie{alice}, stream: true,
+		header: http.Header{
+			"Connection":            {"Upgrade"},
+			"Upgrade":               {"websocket"},
+			"Sec-Websocket-Version": {"13"},
+			"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+		},
+		status: http.StatusSwitchingProtocols})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/ws", cookies: []*http.Cookie{alice}, status: http.StatusBadRequest})
+
+	// Webhooks
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/webhooks", cookies: []*http.Cookie{alice}, status: http.StatusNoContent})
+	subscription := decode[struct{ ID int64 }](t, c.check(t, apiCall{method: http.MethodPost, path: "/api/user/webhooks",
+		body:    map[string]any{"url": "https://93.184.216.34/hook", "events": []string{"order.status"}, "secret": "secret"},
+		cookies: []*http.Cookie{alice}, status: http.StatusCreated}))
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/webhooks",
+		body:    map[string]any{"url": "http://169.254.169.254/", "events": []string{"order.status"}, "secret": "secret"},
+		cookies: []*http.Cookie{alice}, status: http.StatusBadRequest})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/webhooks", cookies: []*http.Cookie{alice}, status: http.StatusOK})
+	webhook := "/api/user/webhooks/" + strconv.FormatInt(subscription.ID, 10)
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/webhooks/{id}/deliveries",
+		target: webhook + "/deliveries", cookies: []*http.Cookie{alice}, status: http.StatusNoContent})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/webhooks/{id}/deliveries",
+		target: webhook + "/deliveries", cookies: []*http.Cookie{carol}, status: http.StatusNotFound})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/webhooks/deliveries/{id}/replay",
+		target: "/api/user/webhooks/deliveries/999/replay", cookies: []*http.Cookie{alice}, status: http.StatusNotFound})
+	c.check(t, apiCall{method: http.MethodDelete, path: "/api/user/webhooks/{id}",
+		target: webhook, cookies: []*http.Cookie{alice}, status: http.StatusNoContent})
+	c.check(t, apiCall{method: http.MethodDelete, path: "/api/user/webhooks/{id}",
+		target: "/api/user/webhooks/abc", cookies: []*http.Cookie{alice}, status: http.StatusBadRequest})
+
+	// Back office
+	if _, err := ts.userService.SeedAdmin(ctx, "admin", "Passw0rd!"); err != nil {
+		t.Fatalf("failed to seed admin: %v", err)
+	}
+	admin := c.login(t, "admin")
+	user := fmt.Sprintf("/api/admin/users/%d", bobID)
+
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/admin/users",
+		target: "/api/admin/users?login=" + url.QueryEscape("b"), cookies: []*http.Cookie{admin}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/admin/users",
+		target: "/api/admin/users?login=nobody", cookies: []*http.Cookie{admin}, status: http.StatusNoContent})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/admin/users", cookies: []*http.Cookie{alice}, status: http.StatusForbidden})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/admin/users/{id}",
+		target: user, cookies: []*http.Cookie{admin}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/admin/users/{id}",
+		target: "/api/admin/users/999", cookies: []*http.Cookie{admin}, status: http.StatusNotFound})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/admin/orders/{id}/recheck",
+		target: "/api/admin/orders/79927398713/recheck", cookies: []*http.Cookie{admin}, status: http.StatusBadGateway})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/admin/orders/{id}/recheck",
+		target: "/api/admin/orders/2377225624/recheck", cookies: []*http.Cookie{admin}, status: http.StatusNotFound})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/admin/users/{id}/balance/adjustments",
+		target: user + "/balance/adjustments", body: map[string]any{"amount": 100, "reason": "goodwill"},
+		cookies: []*http.Cookie{admin}, status: http.StatusCreated})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/admin/users/{id}/balance/adjustments",
+		target: user + "/balance/adjustments", body: map[string]any{"amount": 100},
+		cookies: []*http.Cookie{admin}, status: http.StatusBadRequest})
+	c.check(t, apiCall{method: http.MethodPut, path: "/api/admin/users/{id}/role",
+		target: user + "/role", body: map[string]string{"role": "support"}, cookies: []*http.Cookie{admin}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/admin/users/{id}/block",
+		target: user + "/block", cookies: []*http.Cookie{admin}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/admin/users/{id}/unblock",
+		target: user + "/unblock", cookies: []*http.Cookie{admin}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/admin/log-level", cookies: []*http.Cookie{admin}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPut, path: "/api/admin/log-level",
+		body: map[string]string{"level": "debug"}, cookies: []*http.Cookie{admin}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodPut, path: "/api/admin/log-level",
+		body: map[string]string{"level": "verbose"}, cookies: []*http.Cookie{admin}, status: http.StatusBadRequest})
+
+	// Balance after the adjustment
+	bob := c.login(t, "bob")
+	c.check(t, apiCall{method: http.MethodPost, path: "/api/user/balance/withdraw",
+		body: WithdrawalRequest{OrderID: "2377225624", Sum: 10}, cookies: []*http.Cookie{bob}, status: http.StatusOK})
+	c.check(t, apiCall{method: http.MethodGet, path: "/api/user/withdrawals", cookies: []*http.Cookie{bob}, status: http.StatusOK})
+
+	// Every operation of the document is exercised
+	for path, item := range c.spec.Paths.Map() {
+		for method := range item.Operations() {
+			if !c.covered[method+" "+path] {
+				t.Errorf("%s %s is not exercised", method, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"encoding/json"
+	"gophermart/domain/service"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUploadOrdersReportsBlankEntries(t *testing.T) {
+	ts := newTestServer(t, nil)
+	session := ts.register(t, "alice")
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []service.OrderUploadResult
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body:        `["", " ", "79927398713"]`,
+			want: []service.OrderUploadResult{
+				{Order: "", Status: service.UploadInvalid},
+				{Order: "", Status: service.UploadInvalid},
+				{Order: "79927398713", Status: service.UploadAccepted},
+			},
+		},
+		{
+			name:        "Text",
+			contentType: "text/plain",
+			body:        "\n12345678903\n\n",
+			want: []service.OrderUploadResult{
+				{Order: "12345678903", Status: service.UploadAccepted},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.AddCookie(session)
+
+			rec := httptest.NewRecorder()
+			ts.handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("batch upload answered %d: %s", rec.Code, rec.Body)
+			}
+
+			var got []service.OrderUploadResult
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode results: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("results = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"gophermart/domain/service"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errInvalidQuery is returned when listing query parameters cannot be parsed
+var errInvalidQuery = errors.New("invalid query parameters")
+
+// isPaginated reports whether the client asked for a paginated listing.
+// Requests without query parameters keep receiving the full list for compatibility.
+func isPaginated(r *http.Request) bool {
+	return r.URL.RawQuery != ""
+}
+
+// parseListParams reads cursor, limit, status, from, to and sort query parameters
+func parseListParams(r *http.Request) (service.ListParams, error) {
+	q := r.URL.Query()
+	params := service.ListParams{Cursor: q.Get("cursor")}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return params, fmt.Errorf("%w: limit", errInvalidQuery)
+		}
+		params.Limit = limit
+	}
+
+	for _, v := range q["status"] {
+		for _, status := range strings.Split(v, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				params.Statuses = append(params.Statuses, strings.ToUpper(status))
+			}
+		}
+	}
+
+	var err error
+	if params.From, err = parseTimeParam(q, "from"); err != nil {
+		return params, err
+	}
+	if params.To, err = parseTimeParam(q, "to"); err != nil {
+		return params, err
+	}
+
+	switch strings.ToLower(q.Get("sort")) {
+	case "", "desc":
+	case "asc":
+		params.Ascending = true
+	default:
+		return params, fmt.Errorf("%w: sort", errInvalidQuery)
+	}
+
+	return params, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter
+func parseTimeParam(q url.Values, name string) (time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s", errInvalidQuery, name)
+	}
+
+	return t, nil
+}
+
+// setPageHeaders advertises the next page with Link and X-Next-Cursor headers
+func setPageHeaders(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("cursor", nextCursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+
+	w.Header().Set("X-Next-Cursor", nextCursor)
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
@@ -2,17 +2,51 @@ package http
 
 import (
 	"context"
+	"gophermart/domain/entity"
 	"gophermart/domain/service"
+	"gophermart/internal/health"
+	"gophermart/internal/metrics"
+	"gophermart/internal/oidc"
+	"log/slog"
 	"net/http"
 	"time"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	server         *http.Server
-	userService    *service.UserService
-	orderService   *service.OrderService
-	balanceService *service.BalanceService
+	server             *http.Server
+	userService        *service.UserService
+	orderService       *service.OrderService
+	balanceService     *service.BalanceService
+	identityService    *service.IdentityService
+	adminService       *service.AdminService
+	idempotencyService *service.IdempotencyService
+	webhookService     *service.WebhookService
+	events             *service.EventBus
+	oidcProvider       *oidc.Provider
+	health             *health.Checker
+	metrics            *metrics.Metrics
+	logger             *slog.Logger
+	logLevel           *slog.LevelVar
+	shutdownCh         chan struct{}
+	routes             []string // Patterns registered in NewServer, each is described in the OpenAPI document
+}
+
+// routeMux is a ServeMux that remembers the registered patterns
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+// Handle registers the handler for the pattern
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for the pattern
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
 }
 
 // NewServer creates a new HTTP server
@@ -21,34 +55,98 @@ func NewServer(
 	userService *service.UserService,
 	orderService *service.OrderService,
 	balanceService *service.BalanceService,
+	identityService *service.IdentityService,
+	adminService *service.AdminService,
+	idempotencyService *service.IdempotencyService,
+	webhookService *service.WebhookService,
+	events *service.EventBus,
+	oidcProvider *oidc.Provider,
+	health *health.Checker,
+	metrics *metrics.Metrics,
+	logger *slog.Logger,
+	logLevel *slog.LevelVar,
 ) *Server {
 	server := &Server{
-		userService:    userService,
-		orderService:   orderService,
-		balanceService: balanceService,
+		userService:        userService,
+		orderService:       orderService,
+		balanceService:     balanceService,
+		identityService:    identityService,
+		adminService:       adminService,
+		idempotencyService: idempotencyService,
+		webhookService:     webhookService,
+		events:             events,
+		shutdownCh:         make(chan struct{}),
+		oidcProvider:       oidcProvider,
+		health:             health,
+		metrics:            metrics,
+		logger:             logger,
+		logLevel:           logLevel,
 	}
 
-	mux := http.NewServeMux()
+	mux := &routeMux{ServeMux: http.NewServeMux()}
+
+	// API description and operational endpoints
+	mux.HandleFunc("/api/openapi.json", server.getOpenAPISpec)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", server.getLiveness)
+	mux.HandleFunc("/readyz", server.getReadiness)
 
 	// User endpoints
 	mux.HandleFunc("/api/user/register", server.register)
 	mux.HandleFunc("/api/user/login", server.login)
+	mux.HandleFunc("/api/user/login/2fa", server.loginSecondFactor)
+	mux.HandleFunc("/api/user/2fa/enroll", server.withAuth(server.enrollTwoFactor))
+	mux.HandleFunc("/api/user/2fa/verify", server.withAuth(server.verifyTwoFactor))
+	mux.HandleFunc("/api/user/2fa/disable", server.withAuth(server.disableTwoFactor))
+	// External identity provider endpoints, only when OIDC is configured
+	if oidcProvider != nil {
+		mux.HandleFunc("/api/user/oidc/login", server.oidcLogin)
+		mux.HandleFunc("/api/user/oidc/callback", server.oidcCallback)
+	}
+
+	mux.HandleFunc("/api/user/password", server.withAuth(server.changePassword))
+	mux.HandleFunc("/api/user/password/reset", server.requestPasswordReset)
+	mux.HandleFunc("/api/user/password/reset/confirm", server.confirmPasswordReset)
 
 	// Order endpoints
-	mux.HandleFunc("/api/user/orders", server.withAuth(server.handleOrders))
+	mux.HandleFunc("/api/user/orders", server.withAuth(server.withIdempotency(server.handleOrders)))
+	mux.HandleFunc("/api/user/orders/batch", server.withAuth(server.withIdempotency(server.uploadOrders)))
 
 	// Balance endpoints
 	mux.HandleFunc("/api/user/balance", server.withAuth(server.getBalance))
-	mux.HandleFunc("/api/user/balance/withdraw", server.withAuth(server.withdraw))
+	mux.HandleFunc("/api/user/balance/withdraw", server.withAuth(server.withIdempotency(server.withdraw)))
 	mux.HandleFunc("/api/user/withdrawals", server.withAuth(server.getWithdrawals))
+	mux.HandleFunc("/api/user/events", server.withAuth(server.streamEvents))
+	mux.HandleFunc("/api/user/ws", server.withAuth(server.streamEventsWebSocket))
+
+	// Webhook endpoints
+	mux.HandleFunc("/api/user/webhooks", server.withAuth(server.withIdempotency(server.handleWebhooks)))
+	mux.HandleFunc("/api/user/webhooks/{id}", server.withAuth(server.deleteWebhook))
+	mux.HandleFunc("/api/user/webhooks/{id}/deliveries", server.withAuth(server.getWebhookDeliveries))
+	mux.HandleFunc("/api/user/webhooks/deliveries/{id}/replay", server.withAuth(server.withIdempotency(server.replayWebhookDelivery)))
+
+	// Back-office endpoints, read-only ones are available to support staff
+	mux.HandleFunc("/api/admin/users", server.withRole(server.searchUsers, entity.RoleSupport, entity.RoleAdmin))
+	mux.HandleFunc("/api/admin/users/{id}", server.withRole(server.getUserOverview, entity.RoleSupport, entity.RoleAdmin))
+	mux.HandleFunc("/api/admin/orders/{id}/recheck",
+		server.withRole(server.withIdempotency(server.recheckOrder), entity.RoleSupport, entity.RoleAdmin))
+	mux.HandleFunc("/api/admin/users/{id}/role", server.withRole(server.setUserRole, entity.RoleAdmin))
+	mux.HandleFunc("/api/admin/users/{id}/balance/adjustments",
+		server.withRole(server.withIdempotency(server.adjustBalance), entity.RoleAdmin))
+	mux.HandleFunc("/api/admin/users/{id}/block", server.withRole(server.blockUser, entity.RoleAdmin))
+	mux.HandleFunc("/api/admin/users/{id}/unblock", server.withRole(server.unblockUser, entity.RoleAdmin))
+	mux.HandleFunc("/api/admin/log-level", server.withRole(server.handleLogLevel, entity.RoleAdmin))
 
+	server.routes = mux.patterns
 	server.server = &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      chain(mux, withRequestID, server.withAccessLog, withTracing, server.withMetrics, server.withRecovery),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	// Shutdown does not wait for streaming responses to end on their own
+	server.server.RegisterOnShutdown(func() { close(server.shutdownCh) })
 
 	return server
 }
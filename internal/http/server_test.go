@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gophermart/domain/service"
+	"gophermart/internal/health"
+	"gophermart/internal/metrics"
+	"gophermart/internal/notify"
+	"gophermart/internal/oidc"
+	"gophermart/internal/sqlite"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testServer is an HTTP server backed by a fresh SQLite database
+type testServer struct {
+	*Server
+	handler         http.Handler
+	userService     *service.UserService
+	identityService *service.IdentityService
+}
+
+// newTestServer creates a server with all services wired to a temporary database.
+// The OIDC endpoints are registered when oidcProvider is not nil.
+func newTestServer(t *testing.T, oidcProvider *oidc.Provider) *testServer {
+	t.Helper()
+
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "gophermart.db"), nil, logger)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := sqlite.NewMigrator(db, logger)
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	userRepo := sqlite.NewUserRepo(db)
+	orderRepo := sqlite.NewOrderRepo(db)
+	balanceRepo := sqlite.NewBalanceRepo(db)
+	withdrawalRepo := sqlite.NewWithdrawalRepo(db)
+	outboxRepo := sqlite.NewOutboxRepo(db)
+	transactor := sqlite.NewTransactor(db)
+
+	appMetrics := metrics.New()
+	events := service.NewEventBus(service.DefaultEventHistory)
+
+	userService := service.NewUserService(
+		userRepo,
+		sqlite.NewPasswordResetRepo(db),
+		sqlite.NewRecoveryCodeRepo(db),
+		notify.NewLogNotifier(logger),
+		service.PasswordPolicy{MinLength: 8},
+		time.Hour,
+		logger,
+	)
+	identityService := service.NewIdentityService(userRepo, sqlite.NewExternalIdentityRepo(db), logger)
+	orderService := service.NewOrderService(orderRepo, balanceRepo, outboxRepo, transactor, events, appMetrics, logger)
+	balanceService := service.NewBalanceService(
+		balanceRepo,
+		withdrawalRepo,
+		orderRepo,
+		outboxRepo,
+		transactor,
+		events,
+		appMetrics,
+		logger,
+	)
+	accrualService := service.NewAccrualService(
+		orderRepo,
+		orderService,
+		"http://127.0.0.1:1",
+		time.Minute,
+		appMetrics,
+		logger,
+	)
+	adminService := service.NewAdminService(
+		userRepo,
+		orderRepo,
+		balanceRepo,
+		withdrawalRepo,
+		outboxRepo,
+		transactor,
+		orderService,
+		accrualService,
+		events,
+		logger,
+	)
+	idempotencyService := service.NewIdempotencyService(sqlite.NewIdempotencyRepo(db), time.Hour, time.Hour, logger)
+	webhookService := service.NewWebhookService(
+		sqlite.NewWebhookRepo(db),
+		outboxRepo,
+		transactor,
+		3,
+		time.Second,
+		time.Second,
+		false,
+		logger,
+	)
+
+	checker := health.NewChecker()
+	checker.Add("database", db.PingContext)
+	checker.Add("migrations", migrator.CheckApplied)
+
+	server := NewServer(
+		"127.0.0.1:0",
+		userService,
+		orderService,
+		balanceService,
+		identityService,
+		adminService,
+		idempotencyService,
+		webhookService,
+		events,
+		oidcProvider,
+		checker,
+		appMetrics,
+		logger,
+		new(slog.LevelVar),
+	)
+
+	return &testServer{
+		Server:          server,
+		handler:         server.server.Handler,
+		userService:     userService,
+		identityService: identityService,
+	}
+}
+
+// do sends a request to the server, a non-nil body is encoded as JSON
+func (s *testServer) do(t *testing.T, method, target string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// register creates a user and returns its session cookie
+func (s *testServer) register(t *testing.T, login string) *http.Cookie {
+	t.Helper()
+
+	rec := s.do(t, http.MethodPost, "/api/user/register", UserCredentials{Login: login, Password: "Passw0rd!"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register %q answered %d: %s", login, rec.Code, rec.Body)
+	}
+
+	return cookieNamed(t, rec, "token")
+}
+
+// cookieNamed returns a cookie set by the response
+func cookieNamed(t *testing.T, rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	t.Helper()
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+
+	t.Fatalf("response sets no %q cookie", name)
+	return nil
+}
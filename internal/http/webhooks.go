@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// WebhookRequest represents a request to register a webhook subscription
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// handleWebhooks handles both registering and listing webhook subscriptions
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request, userID int64) {
+	switch r.Method {
+	case http.MethodPost:
+		s.createWebhook(w, r, userID)
+	case http.MethodGet:
+		s.getWebhooks(w, r, userID)
+	default:
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// createWebhook registers a webhook subscription
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	subscription, err := s.webhookService.CreateSubscription(r.Context(), userID, req.URL, req.Events, req.Secret)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, subscription)
+}
+
+// getWebhooks lists the user's webhook subscriptions
+func (s *Server) getWebhooks(w http.ResponseWriter, r *http.Request, userID int64) {
+	subscriptions, err := s.webhookService.GetUserSubscriptions(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	if len(subscriptions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscriptions)
+}
+
+// deleteWebhook deletes a webhook subscription and its deliveries
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodDelete {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subscriptionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := s.webhookService.DeleteSubscription(r.Context(), userID, subscriptionID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getWebhookDeliveries lists the most recent deliveries of a webhook subscription
+func (s *Server) getWebhookDeliveries(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subscriptionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	deliveries, err := s.webhookService.GetDeliveries(r.Context(), userID, subscriptionID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// replayWebhookDelivery queues a dead delivery again
+func (s *Server) replayWebhookDelivery(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := s.webhookService.ReplayDelivery(r.Context(), userID, deliveryID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, delivery)
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"gophermart/domain/service"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteWait is the time allowed to write a frame, a client that reads slower is disconnected
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is the time allowed to read the next pong from the client
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod is the interval of heartbeat pings, it must be shorter than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsMaxMessageSize is the maximum size of a message from the client
+	wsMaxMessageSize = 4096
+)
+
+// The default CheckOrigin rejects cross-origin browser requests, the cookie alone must not authorize them
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Failed handshakes are answered like every other error of the API
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		writeProblem(w, r, status, reason.Error())
+	},
+}
+
+// wsClientMessage is a message sent by the client to change its subscriptions
+type wsClientMessage struct {
+	Type   string   `json:"type"`
+	Events []string `json:"events"`
+}
+
+// wsServerMessage is a control message sent to the client
+type wsServerMessage struct {
+	Type   string   `json:"type"`
+	Events []string `json:"events,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// streamEventsWebSocket pushes the user's balance, order and withdrawal events over a WebSocket.
+// Clients narrow the stream with the events query parameter or subscribe/unsubscribe messages.
+func (s *Server) streamEventsWebSocket(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter := service.EventTypes
+	if events := r.URL.Query().Get("events"); events != "" {
+		var invalid string
+		filter, invalid = parseEventTypes(strings.Split(events, ","))
+		if invalid != "" {
+			writeProblem(w, r, http.StatusBadRequest, "Unknown event type "+invalid)
+			return
+		}
+	}
+
+	// A missing or malformed last_event_id starts a fresh stream
+	lastEventID, _ := strconv.ParseUint(r.URL.Query().Get("last_event_id"), 10, 64)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an error
+		return
+	}
+	defer conn.Close()
+
+	replay, sub := s.events.Subscribe(userID, lastEventID)
+	defer sub.Cancel()
+
+	// The reader goroutine owns reads, this goroutine owns writes
+	messages := make(chan wsClientMessage)
+	readerDone := make(chan struct{})
+	go readClientMessages(conn, messages, readerDone)
+
+	for _, event := range replay {
+		if slices.Contains(filter, event.Type) {
+			if err := writeFrame(conn, event); err != nil {
+				return
+			}
+		}
+	}
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				// The client fell behind, it may reconnect with the ID of the last event it received
+				closeWebSocket(conn, websocket.CloseTryAgainLater, "client too slow")
+				return
+			}
+			if !slices.Contains(filter, event.Type) {
+				continue
+			}
+			if err := writeFrame(conn, event); err != nil {
+				return
+			}
+		case msg := <-messages:
+			reply := wsServerMessage{Type: "subscriptions"}
+			var invalid string
+			switch msg.Type {
+			case "subscribe":
+				var events []string
+				if events, invalid = parseEventTypes(msg.Events); invalid == "" {
+					filter = mergeEventTypes(filter, events)
+				}
+			case "unsubscribe":
+				var events []string
+				if events, invalid = parseEventTypes(msg.Events); invalid == "" {
+					filter = slices.DeleteFunc(slices.Clone(filter), func(t string) bool {
+						return slices.Contains(events, t)
+					})
+				}
+			default:
+				reply = wsServerMessage{Type: "error", Error: "unknown message type " + strconv.Quote(msg.Type)}
+			}
+			if invalid != "" {
+				reply = wsServerMessage{Type: "error", Error: "unknown event type " + strconv.Quote(invalid)}
+			} else if reply.Type == "subscriptions" {
+				reply.Events = filter
+			}
+			if err := writeFrame(conn, reply); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-readerDone:
+			return
+		case <-s.shutdownCh:
+			closeWebSocket(conn, websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+	}
+}
+
+// readClientMessages reads subscription messages until the connection fails or the client goes silent
+func readClientMessages(conn *websocket.Conn, messages chan<- wsClientMessage, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg wsClientMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		select {
+		case messages <- msg:
+		case <-time.After(wsWriteWait):
+			// The writer has stopped
+			return
+		}
+	}
+}
+
+// writeFrame writes a JSON text frame within the write deadline
+func writeFrame(conn *websocket.Conn, v any) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(v)
+}
+
+// closeWebSocket sends a close frame, the connection is closed by the caller
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+}
+
+// parseEventTypes validates event type names, returning the first unknown one
+func parseEventTypes(names []string) ([]string, string) {
+	types := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(service.EventTypes, name) {
+			return nil, name
+		}
+		types = mergeEventTypes(types, []string{name})
+	}
+	return types, ""
+}
+
+// mergeEventTypes returns the union of two lists of event types
+func mergeEventTypes(a, b []string) []string {
+	merged := slices.Clone(a)
+	for _, t := range b {
+		if !slices.Contains(merged, t) {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAcceptRequestID(t *testing.T) {
+	for _, id := range []string{"abc-123", strings.Repeat("a", maxRequestIDLength)} {
+		if got := AcceptRequestID(id); got != id {
+			t.Errorf("AcceptRequestID(%q) = %q, want it unchanged", id, got)
+		}
+	}
+
+	for _, id := range []string{"", "has space", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		got := AcceptRequestID(id)
+		if got == id || len(got) != 16 {
+			t.Errorf("AcceptRequestID(%q) = %q, want a new request ID", id, got)
+		}
+	}
+}
+
+func TestRequestIDIsLogged(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, nil)
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc-123")
+	logger.InfoContext(ctx, "Handled")
+
+	if RequestID(ctx) != "abc-123" {
+		t.Errorf("RequestID() = %q, want %q", RequestID(ctx), "abc-123")
+	}
+	if !strings.Contains(buf.String(), `"request_id":"abc-123"`) {
+		t.Errorf("log record %s has no request_id", buf.String())
+	}
+}
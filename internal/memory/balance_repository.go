@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"maps"
+	"slices"
+)
+
+// BalanceRepo implements the BalanceRepository interface
+type BalanceRepo struct {
+	mu               txMutex
+	balances         map[int64]entity.Balance
+	adjustments      []entity.BalanceAdjustment
+	nextAdjustmentID int64
+}
+
+// NewBalanceRepo creates a new empty BalanceRepo instance
+func NewBalanceRepo() *BalanceRepo {
+	return &BalanceRepo{balances: make(map[int64]entity.Balance)}
+}
+
+// GetOrCreate retrieves or creates a balance record for a user
+func (r *BalanceRepo) GetOrCreate(ctx context.Context, userID int64) (*entity.Balance, error) {
+	defer r.mu.lock(ctx)()
+
+	balance := r.getOrCreate(userID)
+	return &balance, nil
+}
+
+// UpdateBalance accrues or withdraws points, a withdrawal must not exceed the current balance
+func (r *BalanceRepo) UpdateBalance(ctx context.Context, userID int64, amount float64, isWithdrawal bool) error {
+	defer r.mu.lock(ctx)()
+
+	balance := r.getOrCreate(userID)
+
+	if isWithdrawal {
+		if balance.Current < amount {
+			return repository.ErrInsufficientFunds
+		}
+		balance.Current -= amount
+		balance.Withdrawn += amount
+	} else {
+		balance.Current += amount
+	}
+
+	balance.UpdatedAt = now()
+	r.balances[userID] = balance
+
+	return nil
+}
+
+// Adjust applies a signed manual adjustment to an existing balance and records it
+func (r *BalanceRepo) Adjust(ctx context.Context, adjustment *entity.BalanceAdjustment) error {
+	defer r.mu.lock(ctx)()
+
+	balance, ok := r.balances[adjustment.UserID]
+	if !ok {
+		return fmt.Errorf("balance not found: %w", repository.ErrNotFound)
+	}
+
+	// Debits must not make the balance negative
+	if balance.Current+adjustment.Amount < 0 {
+		return repository.ErrInsufficientFunds
+	}
+
+	balance.Current += adjustment.Amount
+	balance.UpdatedAt = now()
+	r.balances[adjustment.UserID] = balance
+
+	r.nextAdjustmentID++
+	adjustment.ID = r.nextAdjustmentID
+	adjustment.CreatedAt = balance.UpdatedAt
+	r.adjustments = append(r.adjustments, *adjustment)
+
+	return nil
+}
+
+// GetAdjustmentsByUserID retrieves all manual adjustments of a user's balance, newest first
+func (r *BalanceRepo) GetAdjustmentsByUserID(ctx context.Context, userID int64) ([]entity.BalanceAdjustment, error) {
+	defer r.mu.lock(ctx)()
+
+	var adjustments []entity.BalanceAdjustment
+	for _, adjustment := range r.adjustments {
+		if adjustment.UserID == userID {
+			adjustments = append(adjustments, adjustment)
+		}
+	}
+
+	slices.SortFunc(adjustments, func(a, b entity.BalanceAdjustment) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+
+	return adjustments, nil
+}
+
+// getOrCreate returns the balance of the user, storing an empty one first if there is none
+func (r *BalanceRepo) getOrCreate(userID int64) entity.Balance {
+	balance, ok := r.balances[userID]
+	if !ok {
+		balance = entity.Balance{UserID: userID, UpdatedAt: now()}
+		r.balances[userID] = balance
+	}
+	return balance
+}
+
+// mutex returns the mutex guarding the balances and adjustments
+func (r *BalanceRepo) mutex() *txMutex {
+	return &r.mu
+}
+
+// snapshot copies the balances and adjustments and returns a function that restores them
+func (r *BalanceRepo) snapshot() func() {
+	balances, adjustments := maps.Clone(r.balances), slices.Clone(r.adjustments)
+
+	return func() {
+		r.balances, r.adjustments = balances, adjustments
+	}
+}
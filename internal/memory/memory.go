@@ -0,0 +1,11 @@
+// Package memory implements the user, order, balance, withdrawal and outbox repositories and a Transactor
+// in memory. The repositories are safe for concurrent use and follow the semantics of the postgres ones,
+// which makes them suitable for service tests and demos that should not need a database.
+package memory
+
+import "time"
+
+// now returns the current time at the microsecond precision of Postgres timestamps
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
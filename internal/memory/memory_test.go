@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"gophermart/domain/repository/repotest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(testing.TB) repotest.Repositories {
+		users, orders, balances := NewUserRepo(), NewOrderRepo(), NewBalanceRepo()
+		withdrawals, outbox := NewWithdrawalRepo(), NewOutboxRepo()
+
+		return repotest.Repositories{
+			Users:       users,
+			Orders:      orders,
+			Balances:    balances,
+			Withdrawals: withdrawals,
+			Outbox:      outbox,
+			Transactor:  NewTransactor(users, orders, balances, withdrawals, outbox),
+		}
+	})
+}
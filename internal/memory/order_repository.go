@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// OrderRepo implements the OrderRepository interface
+type OrderRepo struct {
+	mu     txMutex
+	orders map[string]entity.Order
+}
+
+// NewOrderRepo creates a new empty OrderRepo instance
+func NewOrderRepo() *OrderRepo {
+	return &OrderRepo{orders: make(map[string]entity.Order)}
+}
+
+// Create adds a new order, order numbers are unique
+func (r *OrderRepo) Create(ctx context.Context, order *entity.Order) error {
+	defer r.mu.lock(ctx)()
+
+	if _, ok := r.orders[order.ID]; ok {
+		return fmt.Errorf("order already exists: %w", repository.ErrAlreadyExists)
+	}
+
+	order.UploadedAt = now()
+	r.orders[order.ID] = entity.Order{
+		ID:         order.ID,
+		UserID:     order.UserID,
+		Status:     order.Status,
+		UploadedAt: order.UploadedAt,
+	}
+
+	return nil
+}
+
+// CreateBatch inserts new orders at once.
+// For orders that already exist it reports the owner instead of inserting them.
+func (r *OrderRepo) CreateBatch(ctx context.Context, userID int64, ids []string) ([]repository.BatchInsertResult, error) {
+	defer r.mu.lock(ctx)()
+
+	uploadedAt := now()
+	seen := make(map[string]bool, len(ids))
+
+	var results []repository.BatchInsertResult
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if existing, ok := r.orders[id]; ok {
+			results = append(results, repository.BatchInsertResult{ID: id, OwnerID: existing.UserID})
+			continue
+		}
+
+		r.orders[id] = entity.Order{ID: id, UserID: userID, Status: entity.StatusNew, UploadedAt: uploadedAt}
+		results = append(results, repository.BatchInsertResult{ID: id, Inserted: true})
+	}
+
+	return results, nil
+}
+
+// GetByID retrieves an order by ID
+func (r *OrderRepo) GetByID(ctx context.Context, id string) (*entity.Order, error) {
+	defer r.mu.rlock(ctx)()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("order not found: %w", repository.ErrNotFound)
+	}
+
+	return &order, nil
+}
+
+// GetByUserID retrieves all orders for a user, newest first
+func (r *OrderRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Order, error) {
+	return r.List(ctx, repository.OrderFilter{UserID: userID, Limit: -1})
+}
+
+// List retrieves a page of a user's orders using keyset pagination on (uploaded_at, id).
+// A negative limit returns all matching orders.
+func (r *OrderRepo) List(ctx context.Context, filter repository.OrderFilter) ([]entity.Order, error) {
+	defer r.mu.rlock(ctx)()
+
+	var orders []entity.Order
+	for _, order := range r.orders {
+		if order.UserID != filter.UserID {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, order.Status) {
+			continue
+		}
+		if !filter.From.IsZero() && order.UploadedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !order.UploadedAt.Before(filter.To) {
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	compare := func(a, b entity.Order) int {
+		return cmp.Or(a.UploadedAt.Compare(b.UploadedAt), strings.Compare(a.ID, b.ID))
+	}
+	slices.SortFunc(orders, func(a, b entity.Order) int {
+		if filter.Ascending {
+			return compare(a, b)
+		}
+		return compare(b, a)
+	})
+
+	if filter.After != nil {
+		cursor := entity.Order{ID: filter.After.ID, UploadedAt: filter.After.Time}
+		orders = slices.DeleteFunc(orders, func(order entity.Order) bool {
+			if filter.Ascending {
+				return compare(order, cursor) <= 0
+			}
+			return compare(order, cursor) >= 0
+		})
+	}
+
+	if filter.Limit >= 0 && len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+	}
+
+	return orders, nil
+}
+
+// GetByStatuses retrieves up to limit orders of any user with one of the statuses, oldest first
+func (r *OrderRepo) GetByStatuses(ctx context.Context, statuses []string, limit int) ([]entity.Order, error) {
+	defer r.mu.rlock(ctx)()
+
+	var orders []entity.Order
+	for _, order := range r.orders {
+		if slices.Contains(statuses, order.Status) {
+			orders = append(orders, order)
+		}
+	}
+
+	slices.SortFunc(orders, func(a, b entity.Order) int {
+		return cmp.Or(a.UploadedAt.Compare(b.UploadedAt), strings.Compare(a.ID, b.ID))
+	})
+
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+
+	return orders, nil
+}
+
+// CountByStatuses counts the orders of all users with one of the statuses
+func (r *OrderRepo) CountByStatuses(ctx context.Context, statuses []string) (int, error) {
+	defer r.mu.rlock(ctx)()
+
+	count := 0
+	for _, order := range r.orders {
+		if slices.Contains(statuses, order.Status) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// UpdateStatus sets the status and accrual of an order that still has the status from
+// and reports whether it did, so concurrent updates of the same order apply only once
+func (r *OrderRepo) UpdateStatus(ctx context.Context, order *entity.Order, from string) (bool, error) {
+	defer r.mu.lock(ctx)()
+
+	// Like the UPDATE of the postgres repository, a missing order is not an error
+	stored, ok := r.orders[order.ID]
+	if !ok || stored.Status != from {
+		return false, nil
+	}
+
+	stored.Status = order.Status
+	stored.Accrual = order.Accrual
+	r.orders[order.ID] = stored
+
+	return true, nil
+}
+
+// CheckExists checks if an order exists and returns the user ID if it does
+func (r *OrderRepo) CheckExists(ctx context.Context, id string) (bool, int64, error) {
+	defer r.mu.rlock(ctx)()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return false, 0, nil
+	}
+
+	return true, order.UserID, nil
+}
+
+// mutex returns the mutex guarding the orders
+func (r *OrderRepo) mutex() *txMutex {
+	return &r.mu
+}
+
+// snapshot copies the orders and returns a function that restores them
+func (r *OrderRepo) snapshot() func() {
+	orders := maps.Clone(r.orders)
+
+	return func() {
+		r.orders = orders
+	}
+}
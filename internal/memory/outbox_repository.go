@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"slices"
+	"time"
+)
+
+// outboxRecord is an outbox event with the state the postgres repository keeps in the outbox_events table
+type outboxRecord struct {
+	event        entity.OutboxEvent
+	claimedUntil time.Time
+	fannedOutAt  *time.Time
+}
+
+// OutboxRepo implements the OutboxRepository interface
+type OutboxRepo struct {
+	mu      txMutex
+	records []outboxRecord // in the order the events were recorded
+	nextID  int64
+}
+
+// NewOutboxRepo creates a new empty OutboxRepo instance
+func NewOutboxRepo() *OutboxRepo {
+	return &OutboxRepo{}
+}
+
+// Add records events, they are rolled back with the transaction of ctx if there is one
+func (r *OutboxRepo) Add(ctx context.Context, events ...entity.OutboxEvent) error {
+	defer r.mu.lock(ctx)()
+
+	createdAt := now()
+	for _, event := range events {
+		r.nextID++
+		r.records = append(r.records, outboxRecord{event: entity.OutboxEvent{
+			ID:        r.nextID,
+			Type:      event.Type,
+			UserID:    event.UserID,
+			Payload:   slices.Clone(event.Payload),
+			CreatedAt: createdAt,
+		}})
+	}
+
+	return nil
+}
+
+// ClaimUnpublished leases up to limit of the oldest unpublished events that are not claimed
+// and returns them in the order they were recorded
+func (r *OutboxRepo) ClaimUnpublished(ctx context.Context, limit int, lease time.Duration) ([]entity.OutboxEvent, error) {
+	defer r.mu.lock(ctx)()
+
+	claimedAt := now()
+
+	var events []entity.OutboxEvent
+	for i := range r.records {
+		if len(events) == limit {
+			break
+		}
+
+		record := &r.records[i]
+		if record.event.PublishedAt != nil || record.claimedUntil.After(claimedAt) {
+			continue
+		}
+
+		record.claimedUntil = claimedAt.Add(lease)
+		events = append(events, record.event)
+	}
+
+	return events, nil
+}
+
+// ReleaseClaims makes claimed events available to the next claim again
+func (r *OutboxRepo) ReleaseClaims(ctx context.Context, ids []int64) error {
+	r.update(ctx, ids, func(record *outboxRecord) {
+		record.claimedUntil = time.Time{}
+	})
+
+	return nil
+}
+
+// MarkPublished marks events as published
+func (r *OutboxRepo) MarkPublished(ctx context.Context, ids []int64) error {
+	publishedAt := now()
+	r.update(ctx, ids, func(record *outboxRecord) {
+		record.event.PublishedAt = &publishedAt
+	})
+
+	return nil
+}
+
+// RecordFailure records a failed attempt to publish an event
+func (r *OutboxRepo) RecordFailure(ctx context.Context, id int64, reason string) error {
+	r.update(ctx, []int64{id}, func(record *outboxRecord) {
+		record.event.Attempts++
+		record.event.LastError = reason
+	})
+
+	return nil
+}
+
+// GetNotFannedOut retrieves the oldest events not yet queued for webhooks in the order they were recorded.
+// Transactions are serialized, so concurrent workers wait for the one holding the events.
+func (r *OutboxRepo) GetNotFannedOut(ctx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	defer r.mu.lock(ctx)()
+
+	var events []entity.OutboxEvent
+	for _, record := range r.records {
+		if len(events) == limit {
+			break
+		}
+		if record.fannedOutAt == nil {
+			events = append(events, record.event)
+		}
+	}
+
+	return events, nil
+}
+
+// MarkFannedOut marks events as queued for webhooks
+func (r *OutboxRepo) MarkFannedOut(ctx context.Context, ids []int64) error {
+	fannedOutAt := now()
+	r.update(ctx, ids, func(record *outboxRecord) {
+		record.fannedOutAt = &fannedOutAt
+	})
+
+	return nil
+}
+
+// DeletePublished deletes events published and queued for webhooks before the given time
+func (r *OutboxRepo) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	defer r.mu.lock(ctx)()
+
+	count := len(r.records)
+	r.records = slices.DeleteFunc(r.records, func(record outboxRecord) bool {
+		return record.event.PublishedAt != nil && record.event.PublishedAt.Before(before) && record.fannedOutAt != nil
+	})
+
+	return int64(count - len(r.records)), nil
+}
+
+// update applies fn to the events with the IDs, unknown IDs are ignored like by an UPDATE
+func (r *OutboxRepo) update(ctx context.Context, ids []int64, fn func(record *outboxRecord)) {
+	defer r.mu.lock(ctx)()
+
+	for i := range r.records {
+		if slices.Contains(ids, r.records[i].event.ID) {
+			fn(&r.records[i])
+		}
+	}
+}
+
+// mutex returns the mutex guarding the recorded events
+func (r *OutboxRepo) mutex() *txMutex {
+	return &r.mu
+}
+
+// snapshot copies the recorded events and returns a function that restores them
+func (r *OutboxRepo) snapshot() func() {
+	records := slices.Clone(r.records)
+
+	return func() {
+		r.records = records
+	}
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// txKey is the context key of the Transactor whose transaction the calls with the context are made within
+type txKey struct{}
+
+// txMutex guards the state of a repository. A transaction of Transactor holds it until the transaction ends,
+// calls made within the transaction then use the repository without locking it again.
+type txMutex struct {
+	rw sync.RWMutex
+}
+
+// lock locks the mutex for writing unless the transaction of ctx holds it and returns the function unlocking it
+func (m *txMutex) lock(ctx context.Context) func() {
+	if heldByTx(ctx, m) {
+		return func() {}
+	}
+
+	m.rw.Lock()
+	return m.rw.Unlock
+}
+
+// rlock locks the mutex for reading unless the transaction of ctx holds it and returns the function unlocking it
+func (m *txMutex) rlock(ctx context.Context) func() {
+	if heldByTx(ctx, m) {
+		return func() {}
+	}
+
+	m.rw.RLock()
+	return m.rw.RUnlock
+}
+
+// heldByTx reports whether ctx is within a transaction holding m
+func heldByTx(ctx context.Context, m *txMutex) bool {
+	t, ok := ctx.Value(txKey{}).(*Transactor)
+	return ok && slices.ContainsFunc(t.repos, func(repo transactional) bool {
+		return repo.mutex() == m
+	})
+}
+
+// transactional is implemented by the repositories whose changes Transactor rolls back
+type transactional interface {
+	// mutex returns the mutex guarding the state of the repository
+	mutex() *txMutex
+	// snapshot copies the state of the repository and returns a function that restores it,
+	// both are called with the mutex held
+	snapshot() func()
+}
+
+// Transactor implements the Transactor interface for the repositories it was created with.
+// A transaction holds the locks of all the repositories until it ends, so calls outside of it wait
+// like for the row locks of a Postgres transaction and never see its uncommitted changes. A failed
+// transaction restores the state the repositories had when it began. IDs that were handed out are
+// not reused, like the values of a Postgres sequence.
+type Transactor struct {
+	repos []transactional
+}
+
+// NewTransactor creates a new Transactor instance for the repositories sharing its transactions
+func NewTransactor(repos ...transactional) *Transactor {
+	return &Transactor{repos: repos}
+}
+
+// WithinTransaction runs fn within a transaction, joining the transaction of ctx if there is one
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	// The locks are always taken in the same order, so concurrent transactions cannot deadlock
+	for _, repo := range t.repos {
+		repo.mutex().rw.Lock()
+	}
+	defer func() {
+		for _, repo := range t.repos {
+			repo.mutex().rw.Unlock()
+		}
+	}()
+
+	restores := make([]func(), len(t.repos))
+	for i, repo := range t.repos {
+		restores[i] = repo.snapshot()
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		for _, restore := range restores {
+			restore()
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserRepo implements the UserRepository interface
+type UserRepo struct {
+	mu       txMutex
+	users    map[int64]entity.User
+	byLogin  map[string]int64
+	attempts map[int64]totpAttempts
+	nextID   int64
+}
+
+// totpAttempts holds the second factor state kept in the users table by the postgres repository
+type totpAttempts struct {
+	lastStep    int64
+	failures    int
+	attemptedAt time.Time
+}
+
+// NewUserRepo creates a new empty UserRepo instance
+func NewUserRepo() *UserRepo {
+	return &UserRepo{
+		users:    make(map[int64]entity.User),
+		byLogin:  make(map[string]int64),
+		attempts: make(map[int64]totpAttempts),
+	}
+}
+
+// Create adds a new user, logins are unique
+func (r *UserRepo) Create(ctx context.Context, user *entity.User) error {
+	defer r.mu.lock(ctx)()
+
+	if _, ok := r.byLogin[user.Login]; ok {
+		return fmt.Errorf("login already taken: %w", repository.ErrAlreadyExists)
+	}
+
+	r.nextID++
+	// Like the INSERT of the postgres repository only the login, password and role are stored
+	stored := entity.User{
+		ID:        r.nextID,
+		Login:     user.Login,
+		Password:  user.Password,
+		Role:      user.Role,
+		CreatedAt: now(),
+	}
+	r.users[stored.ID] = stored
+	r.byLogin[stored.Login] = stored.ID
+
+	user.ID = stored.ID
+	user.CreatedAt = stored.CreatedAt
+
+	return nil
+}
+
+// GetByLogin retrieves a user by login
+func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*entity.User, error) {
+	unlock := r.mu.rlock(ctx)
+	id, ok := r.byLogin[login]
+	unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("user not found: %w", repository.ErrNotFound)
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+// GetByID retrieves a user by ID
+func (r *UserRepo) GetByID(ctx context.Context, id int64) (*entity.User, error) {
+	defer r.mu.rlock(ctx)()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found: %w", repository.ErrNotFound)
+	}
+
+	return &user, nil
+}
+
+// UpdatePassword stores a new password hash and increments the token version
+func (r *UserRepo) UpdatePassword(ctx context.Context, user *entity.User) error {
+	return r.update(ctx, user.ID, true, func(stored *entity.User) {
+		stored.Password = user.Password
+		stored.TokenVersion++
+		user.TokenVersion = stored.TokenVersion
+	})
+}
+
+// UpdateTOTP stores the user's TOTP secret and enabled flag
+func (r *UserRepo) UpdateTOTP(ctx context.Context, user *entity.User) error {
+	return r.update(ctx, user.ID, false, func(stored *entity.User) {
+		stored.TOTPSecret = user.TOTPSecret
+		stored.TOTPEnabled = user.TOTPEnabled
+	})
+}
+
+// BeginTOTPAttempt counts a second factor attempt unless the user is locked out
+func (r *UserRepo) BeginTOTPAttempt(ctx context.Context, userID int64, maxFailures int, lockout time.Duration) (bool, error) {
+	defer r.mu.lock(ctx)()
+
+	if _, ok := r.users[userID]; !ok {
+		return false, nil
+	}
+
+	attempts := r.attempts[userID]
+	attemptedAt := now()
+	if attempts.failures >= maxFailures {
+		if attemptedAt.Sub(attempts.attemptedAt) < lockout {
+			return false, nil
+		}
+		attempts.failures = 0
+	}
+
+	attempts.failures++
+	attempts.attemptedAt = attemptedAt
+	r.attempts[userID] = attempts
+
+	return true, nil
+}
+
+// AcceptTOTP clears the attempt count and stores the step of the accepted TOTP code
+func (r *UserRepo) AcceptTOTP(ctx context.Context, userID int64, step int64) (bool, error) {
+	defer r.mu.lock(ctx)()
+
+	attempts := r.attempts[userID]
+	if _, ok := r.users[userID]; !ok || (step != 0 && attempts.lastStep >= step) {
+		return false, nil
+	}
+
+	attempts.failures = 0
+	attempts.lastStep = max(attempts.lastStep, step)
+	r.attempts[userID] = attempts
+
+	return true, nil
+}
+
+// UpdateRole stores the user's role
+func (r *UserRepo) UpdateRole(ctx context.Context, user *entity.User) error {
+	return r.update(ctx, user.ID, false, func(stored *entity.User) {
+		stored.Role = user.Role
+	})
+}
+
+// UpdateBlocked stores whether the user is blocked
+func (r *UserRepo) UpdateBlocked(ctx context.Context, user *entity.User) error {
+	return r.update(ctx, user.ID, false, func(stored *entity.User) {
+		stored.Blocked = user.Blocked
+	})
+}
+
+// SearchByLogin retrieves users whose login contains the query, ignoring case
+func (r *UserRepo) SearchByLogin(ctx context.Context, query string, limit int) ([]entity.User, error) {
+	defer r.mu.rlock(ctx)()
+
+	query = strings.ToLower(query)
+
+	var users []entity.User
+	for _, user := range r.users {
+		if strings.Contains(strings.ToLower(user.Login), query) {
+			users = append(users, user)
+		}
+	}
+
+	slices.SortFunc(users, func(a, b entity.User) int {
+		return strings.Compare(a.Login, b.Login)
+	})
+
+	return users[:min(limit, len(users))], nil
+}
+
+// update applies fn to the stored user. Like the postgres statements, only a missing
+// user of an update that returns a value is reported as not found.
+func (r *UserRepo) update(ctx context.Context, id int64, reportMissing bool, fn func(stored *entity.User)) error {
+	defer r.mu.lock(ctx)()
+
+	stored, ok := r.users[id]
+	if !ok {
+		if reportMissing {
+			return fmt.Errorf("user not found: %w", repository.ErrNotFound)
+		}
+		return nil
+	}
+
+	fn(&stored)
+	r.users[id] = stored
+
+	return nil
+}
+
+// mutex returns the mutex guarding the users
+func (r *UserRepo) mutex() *txMutex {
+	return &r.mu
+}
+
+// snapshot copies the users and returns a function that restores them
+func (r *UserRepo) snapshot() func() {
+	users, byLogin, attempts := maps.Clone(r.users), maps.Clone(r.byLogin), maps.Clone(r.attempts)
+
+	return func() {
+		r.users, r.byLogin, r.attempts = users, byLogin, attempts
+	}
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"slices"
+	"strconv"
+)
+
+// WithdrawalRepo implements the WithdrawalRepository interface
+type WithdrawalRepo struct {
+	mu          txMutex
+	withdrawals []entity.Withdrawal
+	nextID      int64
+}
+
+// NewWithdrawalRepo creates a new empty WithdrawalRepo instance
+func NewWithdrawalRepo() *WithdrawalRepo {
+	return &WithdrawalRepo{}
+}
+
+// Create adds a new withdrawal record
+func (r *WithdrawalRepo) Create(ctx context.Context, withdrawal *entity.Withdrawal) error {
+	defer r.mu.lock(ctx)()
+
+	r.nextID++
+	withdrawal.ID = r.nextID
+	withdrawal.ProcessedAt = now()
+	r.withdrawals = append(r.withdrawals, *withdrawal)
+
+	return nil
+}
+
+// GetByUserID retrieves all withdrawals for a user, newest first
+func (r *WithdrawalRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Withdrawal, error) {
+	return r.List(ctx, repository.WithdrawalFilter{UserID: userID, Limit: -1})
+}
+
+// List retrieves a page of a user's withdrawals using keyset pagination on (processed_at, id).
+// A negative limit returns all matching withdrawals.
+func (r *WithdrawalRepo) List(ctx context.Context, filter repository.WithdrawalFilter) ([]entity.Withdrawal, error) {
+	var cursor *entity.Withdrawal
+	if filter.After != nil {
+		id, err := strconv.ParseInt(filter.After.ID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid withdrawal cursor: %w", err)
+		}
+		cursor = &entity.Withdrawal{ID: id, ProcessedAt: filter.After.Time}
+	}
+
+	defer r.mu.rlock(ctx)()
+
+	var withdrawals []entity.Withdrawal
+	for _, withdrawal := range r.withdrawals {
+		if withdrawal.UserID != filter.UserID {
+			continue
+		}
+		if !filter.From.IsZero() && withdrawal.ProcessedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !withdrawal.ProcessedAt.Before(filter.To) {
+			continue
+		}
+		withdrawals = append(withdrawals, withdrawal)
+	}
+
+	compare := func(a, b entity.Withdrawal) int {
+		return cmp.Or(a.ProcessedAt.Compare(b.ProcessedAt), cmp.Compare(a.ID, b.ID))
+	}
+	slices.SortFunc(withdrawals, func(a, b entity.Withdrawal) int {
+		if filter.Ascending {
+			return compare(a, b)
+		}
+		return compare(b, a)
+	})
+
+	if cursor != nil {
+		withdrawals = slices.DeleteFunc(withdrawals, func(withdrawal entity.Withdrawal) bool {
+			if filter.Ascending {
+				return compare(withdrawal, *cursor) <= 0
+			}
+			return compare(withdrawal, *cursor) >= 0
+		})
+	}
+
+	if filter.Limit >= 0 && len(withdrawals) > filter.Limit {
+		withdrawals = withdrawals[:filter.Limit]
+	}
+
+	return withdrawals, nil
+}
+
+// mutex returns the mutex guarding the withdrawals
+func (r *WithdrawalRepo) mutex() *txMutex {
+	return &r.mu
+}
+
+// snapshot copies the withdrawals and returns a function that restores them
+func (r *WithdrawalRepo) snapshot() func() {
+	withdrawals := slices.Clone(r.withdrawals)
+
+	return func() {
+		r.withdrawals = withdrawals
+	}
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of all metrics
+const namespace = "gophermart"
+
+// Metrics holds the Prometheus collectors of the service and implements service.MetricsRecorder
+type Metrics struct {
+	registry        *prometheus.Registry
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	accrualCalls    *prometheus.CounterVec
+	accrualDuration *prometheus.HistogramVec
+	pendingOrders   prometheus.Gauge
+	pollDuration    prometheus.Histogram
+	pointsAccrued   prometheus.Counter
+	pointsWithdrawn prometheus.Counter
+}
+
+// New creates the collectors and registers them together with the Go runtime and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		accrualCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "accrual_requests_total",
+			Help:      "Calls to the accrual system by outcome.",
+		}, []string{"outcome"}),
+		accrualDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "accrual_request_duration_seconds",
+			Help:      "Latency of calls to the accrual system by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		pendingOrders: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pending_orders",
+			Help:      "Orders waiting for the accrual system, as of the last poll.",
+		}),
+		pollDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "accrual_poll_duration_seconds",
+			Help:      "Duration of accrual system poll cycles.",
+			Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 120},
+		}),
+		pointsAccrued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "points_accrued_total",
+			Help:      "Points credited for processed orders.",
+		}),
+		pointsWithdrawn: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "points_withdrawn_total",
+			Help:      "Points withdrawn by users.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.accrualCalls,
+		m.accrualDuration,
+		m.pendingOrders,
+		m.pollDuration,
+		m.pointsAccrued,
+		m.pointsWithdrawn,
+	)
+
+	return m
+}
+
+// RegisterDB exports the connection pool statistics of the database
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveHTTPRequest records a handled HTTP request
+func (m *Metrics) ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveAccrualCall records a call to the accrual system
+func (m *Metrics) ObserveAccrualCall(outcome string, duration time.Duration) {
+	m.accrualCalls.WithLabelValues(outcome).Inc()
+	m.accrualDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// ObservePollCycle records a poll of the accrual system and the number of orders it found pending
+func (m *Metrics) ObservePollCycle(duration time.Duration, pendingOrders int) {
+	m.pollDuration.Observe(duration.Seconds())
+	m.pendingOrders.Set(float64(pendingOrders))
+}
+
+// AddPointsAccrued counts points credited for processed orders
+func (m *Metrics) AddPointsAccrued(points float64) {
+	m.pointsAccrued.Add(points)
+}
+
+// AddPointsWithdrawn counts points withdrawn by users
+func (m *Metrics) AddPointsWithdrawn(points float64) {
+	m.pointsWithdrawn.Add(points)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector exports the statistics of a pgx pool under the names used for database/sql pools,
+// so dashboards work with either Postgres driver
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitedFor         *prometheus.Desc
+	blockedSeconds    *prometheus.Desc
+	closedMaxIdle     *prometheus.Desc
+	closedMaxLifetime *prometheus.Desc
+}
+
+// RegisterPool exports the connection pool statistics of a pgx pool
+func (m *Metrics) RegisterPool(pool *pgxpool.Pool, name string) {
+	labels := prometheus.Labels{"db_name": name}
+	desc := func(fqName, help string) *prometheus.Desc {
+		return prometheus.NewDesc(fqName, help, nil, labels)
+	}
+
+	m.registry.MustRegister(&poolCollector{
+		pool:              pool,
+		maxOpen:           desc("go_sql_max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("go_sql_open_connections", "The number of established connections both in use and idle."),
+		inUse:             desc("go_sql_in_use_connections", "The number of connections currently in use."),
+		idle:              desc("go_sql_idle_connections", "The number of idle connections."),
+		waitedFor:         desc("go_sql_wait_count_total", "The total number of connections waited for."),
+		blockedSeconds:    desc("go_sql_wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+		closedMaxIdle:     desc("go_sql_max_idle_time_closed_total", "The total number of connections closed due to max idle time."),
+		closedMaxLifetime: desc("go_sql_max_lifetime_closed_total", "The total number of connections closed due to max lifetime."),
+	})
+}
+
+// Describe sends the descriptors of the pool statistics
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitedFor
+	ch <- c.blockedSeconds
+	ch <- c.closedMaxIdle
+	ch <- c.closedMaxLifetime
+}
+
+// Collect reads the current pool statistics
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.waitedFor, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.blockedSeconds, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.closedMaxIdle, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(c.closedMaxLifetime, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()))
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gophermart/domain/entity"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileNotifier appends notifications as JSON lines to a file
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier creates a new FileNotifier
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// fileMessage represents a single line written by FileNotifier
+type fileMessage struct {
+	Type   string    `json:"type"`
+	Login  string    `json:"login"`
+	Token  string    `json:"token"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// NotifyPasswordReset appends the password reset token for the user to the file
+func (n *FileNotifier) NotifyPasswordReset(ctx context.Context, user *entity.User, token string) error {
+	return n.write(fileMessage{
+		Type:   "password_reset",
+		Login:  user.Login,
+		Token:  token,
+		SentAt: time.Now(),
+	})
+}
+
+// write appends a message to the file
+func (n *FileNotifier) write(msg fileMessage) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(msg); err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"log/slog"
+)
+
+// LogNotifier writes notifications to the logger, useful for local development
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+// NewLogNotifier creates a new LogNotifier
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogNotifier{logger: logger}
+}
+
+// NotifyPasswordReset logs the password reset token for the user
+func (n *LogNotifier) NotifyPasswordReset(ctx context.Context, user *entity.User, token string) error {
+	n.logger.InfoContext(ctx, "Password reset requested", "login", user.Login, "token", token)
+	return nil
+}
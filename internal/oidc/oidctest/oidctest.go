@@ -0,0 +1,268 @@
+// Package oidctest runs a minimal OpenID Connect identity provider for tests.
+// It serves discovery, JWKS, authorization and token endpoints, checks the PKCE
+// verifier on code exchange and signs ID tokens for the configured account:
+//
+//	idp := oidctest.NewIdP(t)
+//	idp.SetAccount(oidctest.Account{Subject: "42", PreferredUsername: "alice"})
+//	provider, err := oidc.NewProvider(ctx, oidc.Config{Issuer: idp.URL, ClientID: oidctest.ClientID, ...})
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Client credentials accepted by the identity provider
+const (
+	ClientID     = "gophermart"
+	ClientSecret = "secret"
+)
+
+// keyID identifies the signing key in the JWKS
+const keyID = "test-key"
+
+// Account is the user the identity provider authenticates
+type Account struct {
+	Subject           string
+	PreferredUsername string
+	Email             string
+}
+
+// grant is an authorization code issued by the authorization endpoint
+type grant struct {
+	account       Account
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// IdP is a running identity provider
+type IdP struct {
+	*httptest.Server
+
+	key *rsa.PrivateKey
+
+	mu       sync.Mutex
+	account  Account
+	nonce    string
+	grants   map[string]grant
+	nextCode int
+}
+
+// NewIdP starts an identity provider that is closed when the test ends
+func NewIdP(tb testing.TB) *IdP {
+	tb.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatalf("failed to generate signing key: %v", err)
+	}
+
+	idp := &IdP{
+		key:     key,
+		account: Account{Subject: "subject"},
+		grants:  make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+
+	idp.Server = httptest.NewServer(mux)
+	tb.Cleanup(idp.Close)
+
+	return idp
+}
+
+// SetAccount sets the account authenticated by the following authorization requests
+func (p *IdP) SetAccount(account Account) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.account = account
+}
+
+// SetNonce makes the following ID tokens carry nonce instead of the one of the authorization request
+func (p *IdP) SetNonce(nonce string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nonce = nonce
+}
+
+// Authorize follows an authorization URL like a browser of a user who consents,
+// and returns the URL the identity provider redirects back to
+func (p *IdP) Authorize(tb testing.TB, authURL string) *url.URL {
+	tb.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		tb.Fatalf("authorization request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		tb.Fatalf("authorization request answered %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		tb.Fatalf("authorization response has no location: %v", err)
+	}
+
+	return location
+}
+
+// discovery serves the provider metadata
+func (p *IdP) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// jwks serves the public signing key
+func (p *IdP) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize issues a code for the configured account and redirects back to the client
+func (p *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != ClientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE is required", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	p.nextCode++
+	code := "code-" + strconv.Itoa(p.nextCode)
+	p.grants[code] = grant{
+		account:       p.account,
+		redirectURI:   redirectURI.String(),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	p.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges a code for tokens, codes are single-use and bound to the PKCE challenge
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != ClientID || clientSecret != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	code := r.PostForm.Get("code")
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	nonce := p.nonce
+	p.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != g.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	if nonce == "" {
+		nonce = g.nonce
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.URL,
+		"sub":                g.account.Subject,
+		"aud":                ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              nonce,
+		"preferred_username": g.account.PreferredUsername,
+		"email":              g.account.Email,
+	})
+	idToken.Header["kid"] = keyID
+
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-" + code,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
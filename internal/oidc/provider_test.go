@@ -0,0 +1,108 @@
+package oidc
+
+import (
+	"context"
+	"gophermart/internal/oidc/oidctest"
+	"net/url"
+	"testing"
+)
+
+// newTestProvider discovers a provider running against a stub identity provider
+func newTestProvider(t *testing.T) (*Provider, *oidctest.IdP) {
+	t.Helper()
+
+	idp := oidctest.NewIdP(t)
+	provider, err := NewProvider(context.Background(), Config{
+		Issuer:       idp.URL,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://gophermart.test/api/user/oidc/callback",
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+
+	return provider, idp
+}
+
+// authorize runs the authorization request and returns the issued code
+func authorize(t *testing.T, idp *oidctest.IdP, provider *Provider, state, nonce, verifier string) string {
+	t.Helper()
+
+	callback := idp.Authorize(t, provider.AuthCodeURL(state, nonce, verifier))
+	if got := callback.Query().Get("state"); got != state {
+		t.Fatalf("callback state = %q, want %q", got, state)
+	}
+
+	return callback.Query().Get("code")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	provider, _ := newTestProvider(t)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state", "nonce", GenerateVerifier()))
+	if err != nil {
+		t.Fatalf("AuthCodeURL is not a URL: %v", err)
+	}
+
+	query := authURL.Query()
+	for param, want := range map[string]string{
+		"client_id":             oidctest.ClientID,
+		"response_type":         "code",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+	if query.Get("code_challenge") == "" {
+		t.Error("code_challenge is missing")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	idp.SetAccount(oidctest.Account{Subject: "42", PreferredUsername: "alice", Email: "alice@example.com"})
+
+	verifier := GenerateVerifier()
+	code := authorize(t, idp, provider, "state", "nonce", verifier)
+
+	identity, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	want := Identity{Issuer: idp.URL, Subject: "42", PreferredUsername: "alice", Email: "alice@example.com"}
+	if *identity != want {
+		t.Errorf("Exchange = %+v, want %+v", *identity, want)
+	}
+
+	// Codes are single-use
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce"); err == nil {
+		t.Error("Exchange of a used code succeeded")
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	idp.SetNonce("other-nonce")
+
+	verifier := GenerateVerifier()
+	code := authorize(t, idp, provider, "state", "nonce", verifier)
+
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce"); err == nil {
+		t.Error("Exchange accepted an ID token with another nonce")
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	provider, idp := newTestProvider(t)
+
+	code := authorize(t, idp, provider, "state", "nonce", GenerateVerifier())
+
+	if _, err := provider.Exchange(context.Background(), code, GenerateVerifier(), "nonce"); err == nil {
+		t.Error("Exchange succeeded with a verifier that does not match the challenge")
+	}
+}
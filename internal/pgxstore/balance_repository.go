@@ -0,0 +1,206 @@
+package pgxstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BalanceRepo implements the BalanceRepository interface
+type BalanceRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewBalanceRepo creates a new BalanceRepo instance
+func NewBalanceRepo(pool *pgxpool.Pool) *BalanceRepo {
+	return &BalanceRepo{pool: pool}
+}
+
+// GetOrCreate retrieves or creates a balance record for a user
+func (r *BalanceRepo) GetOrCreate(ctx context.Context, userID int64) (*entity.Balance, error) {
+	// Try to get existing balance
+	query := `
+		SELECT user_id, current, withdrawn, updated_at
+		FROM balances
+		WHERE user_id = $1
+	`
+
+	balance := &entity.Balance{UserID: userID}
+	err := conn(ctx, r.pool).QueryRow(ctx, query, userID).Scan(
+		&balance.UserID,
+		&balance.Current,
+		&balance.Withdrawn,
+		&balance.UpdatedAt,
+	)
+
+	if err == nil {
+		return balance, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	// Create new balance if not exists
+	insertQuery := `
+		INSERT INTO balances (user_id, current, withdrawn)
+		VALUES ($1, 0, 0)
+		RETURNING current, withdrawn, updated_at
+	`
+
+	err = conn(ctx, r.pool).QueryRow(ctx, insertQuery, userID).Scan(
+		&balance.Current,
+		&balance.Withdrawn,
+		&balance.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// UpdateBalance updates a user's balance
+func (r *BalanceRepo) UpdateBalance(ctx context.Context, userID int64, amount float64, isWithdrawal bool) error {
+	return inTx(ctx, r.pool, func(tx querier) error {
+		// Make sure the row exists so that a first accrual or withdrawal can lock it
+		_, err := tx.Exec(ctx, `INSERT INTO balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to create balance: %w", err)
+		}
+
+		// Lock the row for update
+		query := `
+			SELECT current, withdrawn FROM balances
+			WHERE user_id = $1
+			FOR UPDATE
+		`
+
+		var current, withdrawn float64
+		err = tx.QueryRow(ctx, query, userID).Scan(&current, &withdrawn)
+		if err != nil {
+			return fmt.Errorf("failed to lock balance row: %w", err)
+		}
+
+		// Check sufficient funds for withdrawal
+		if isWithdrawal && current < amount {
+			return repository.ErrInsufficientFunds
+		}
+
+		// Update based on operation type
+		var updateQuery string
+		if isWithdrawal {
+			updateQuery = `
+				UPDATE balances
+				SET current = current - $1, withdrawn = withdrawn + $1, updated_at = $2
+				WHERE user_id = $3
+			`
+		} else {
+			updateQuery = `
+				UPDATE balances
+				SET current = current + $1, updated_at = $2
+				WHERE user_id = $3
+			`
+		}
+
+		now := time.Now()
+		_, err = tx.Exec(ctx, updateQuery, amount, now, userID)
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Adjust applies a signed manual adjustment to a user's balance and records it
+func (r *BalanceRepo) Adjust(ctx context.Context, adjustment *entity.BalanceAdjustment) error {
+	return inTx(ctx, r.pool, func(tx querier) error {
+		// Lock the row for update
+		var current float64
+		err := tx.QueryRow(ctx, `SELECT current FROM balances WHERE user_id = $1 FOR UPDATE`, adjustment.UserID).Scan(&current)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("balance not found: %w", repository.ErrNotFound)
+			}
+			return fmt.Errorf("failed to lock balance row: %w", err)
+		}
+
+		// Debits must not make the balance negative
+		if current+adjustment.Amount < 0 {
+			return repository.ErrInsufficientFunds
+		}
+
+		updateQuery := `
+			UPDATE balances
+			SET current = current + $1, updated_at = $2
+			WHERE user_id = $3
+		`
+
+		_, err = tx.Exec(ctx, updateQuery, adjustment.Amount, time.Now(), adjustment.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		insertQuery := `
+			INSERT INTO balance_adjustments (user_id, admin_id, amount, reason)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at
+		`
+
+		err = tx.QueryRow(ctx, insertQuery, adjustment.UserID, adjustment.AdminID, adjustment.Amount, adjustment.Reason).Scan(
+			&adjustment.ID,
+			&adjustment.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record adjustment: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetAdjustmentsByUserID retrieves all manual adjustments of a user's balance
+func (r *BalanceRepo) GetAdjustmentsByUserID(ctx context.Context, userID int64) ([]entity.BalanceAdjustment, error) {
+	query := `
+		SELECT id, user_id, admin_id, amount, reason, created_at
+		FROM balance_adjustments
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := conn(ctx, r.pool).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	var adjustments []entity.BalanceAdjustment
+	for rows.Next() {
+		var a entity.BalanceAdjustment
+		err := rows.Scan(
+			&a.ID,
+			&a.UserID,
+			&a.AdminID,
+			&a.Amount,
+			&a.Reason,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan adjustment row: %w", err)
+		}
+		adjustments = append(adjustments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating adjustment rows: %w", err)
+	}
+
+	return adjustments, nil
+}
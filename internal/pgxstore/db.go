@@ -0,0 +1,70 @@
+package pgxstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/internal/postgres"
+	"log/slog"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the SQLSTATE of a unique constraint violation
+const uniqueViolation = "23505"
+
+// statementCacheCapacity is the number of prepared statements each connection keeps
+const statementCacheCapacity = 512
+
+// NewPool creates a connection pool for the DSN, a URL or keyword/value string, and waits for the database to be reachable.
+// The schema is managed by the postgres Migrator.
+func NewPool(ctx context.Context, dsn string, poolCfg postgres.PoolConfig, logger *slog.Logger) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database DSN: %w", err)
+	}
+
+	// Set connection pool parameters, zero values keep the pgx defaults and pgx has no idle connection limit
+	if poolCfg.MaxOpenConns > 0 {
+		cfg.MaxConns = int32(poolCfg.MaxOpenConns)
+	}
+	if poolCfg.ConnMaxLifetime > 0 {
+		cfg.MaxConnLifetime = poolCfg.ConnMaxLifetime
+	}
+	if poolCfg.ConnMaxIdleTime > 0 {
+		cfg.MaxConnIdleTime = poolCfg.ConnMaxIdleTime
+	}
+
+	// A statement_timeout in the DSN takes precedence
+	if _, ok := cfg.ConnConfig.RuntimeParams["statement_timeout"]; !ok && poolCfg.StatementTimeout > 0 {
+		cfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(poolCfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	// Every query is prepared on its first use on a connection and the prepared statement is reused afterwards
+	cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	cfg.ConnConfig.StatementCacheCapacity = statementCacheCapacity
+	cfg.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := postgres.WaitReachable(ctx, pool.Ping, poolCfg.ConnectTimeout, logger); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	logger.Info("Database connected", "host", cfg.ConnConfig.Host, "database", cfg.ConnConfig.Database)
+
+	return pool, nil
+}
+
+// isUniqueViolation reports whether err was caused by a duplicate key
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -0,0 +1,354 @@
+package pgxstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// copyThreshold is the number of order numbers from which CreateBatch loads them with COPY
+const copyThreshold = 100
+
+// createBatchQuery inserts the order numbers selected by the input query for user $1 with status $2.
+// The outer SELECT sees the table as it was before the insert, so it only finds pre-existing orders.
+const createBatchQuery = `
+	WITH input AS (
+		%s
+	), inserted AS (
+		INSERT INTO orders (id, user_id, status)
+		SELECT id, $1, $2 FROM input
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id
+	)
+	SELECT input.id, inserted.id IS NOT NULL, COALESCE(existing.user_id, 0)
+	FROM input
+	LEFT JOIN inserted ON inserted.id = input.id
+	LEFT JOIN orders existing ON existing.id = input.id
+`
+
+// OrderRepo implements the OrderRepository interface
+type OrderRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewOrderRepo creates a new OrderRepo instance
+func NewOrderRepo(pool *pgxpool.Pool) *OrderRepo {
+	return &OrderRepo{pool: pool}
+}
+
+// Create adds a new order to the database
+func (r *OrderRepo) Create(ctx context.Context, order *entity.Order) error {
+	query := `
+		INSERT INTO orders (id, user_id, status)
+		VALUES ($1, $2, $3)
+		RETURNING uploaded_at
+	`
+
+	err := conn(ctx, r.pool).QueryRow(ctx, query, order.ID, order.UserID, order.Status).Scan(&order.UploadedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("order already exists: %w", repository.ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+
+	return nil
+}
+
+// CreateBatch inserts new orders in a single statement, large batches are loaded with COPY first.
+// For orders that already exist it reports the owner instead of inserting them.
+func (r *OrderRepo) CreateBatch(ctx context.Context, userID int64, ids []string) ([]repository.BatchInsertResult, error) {
+	if len(ids) < copyThreshold {
+		query := fmt.Sprintf(createBatchQuery, `SELECT DISTINCT unnest($3::text[]) AS id`)
+
+		rows, err := conn(ctx, r.pool).Query(ctx, query, userID, entity.StatusNew, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create orders: %w", err)
+		}
+
+		return scanBatchResults(rows)
+	}
+
+	var results []repository.BatchInsertResult
+	err := inTx(ctx, r.pool, func(tx querier) error {
+		if _, err := tx.Exec(ctx, `CREATE TEMPORARY TABLE order_import (id TEXT NOT NULL)`); err != nil {
+			return fmt.Errorf("failed to create import table: %w", err)
+		}
+
+		source := pgx.CopyFromSlice(len(ids), func(i int) ([]any, error) {
+			return []any{ids[i]}, nil
+		})
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"order_import"}, []string{"id"}, source); err != nil {
+			return fmt.Errorf("failed to copy order numbers: %w", err)
+		}
+
+		// The import table is new in every transaction, so the statement using it is not cached
+		query := fmt.Sprintf(createBatchQuery, `SELECT DISTINCT id FROM order_import`)
+		rows, err := tx.Query(ctx, query, pgx.QueryExecModeExec, userID, entity.StatusNew)
+		if err != nil {
+			return fmt.Errorf("failed to create orders: %w", err)
+		}
+
+		if results, err = scanBatchResults(rows); err != nil {
+			return err
+		}
+
+		// Drop the table right away, ctx may hold a transaction that imports again
+		if _, err := tx.Exec(ctx, `DROP TABLE order_import`); err != nil {
+			return fmt.Errorf("failed to drop import table: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// scanBatchResults scans the rows returned by createBatchQuery
+func scanBatchResults(rows pgx.Rows) ([]repository.BatchInsertResult, error) {
+	defer rows.Close()
+
+	var results []repository.BatchInsertResult
+	for rows.Next() {
+		var res repository.BatchInsertResult
+		if err := rows.Scan(&res.ID, &res.Inserted, &res.OwnerID); err != nil {
+			return nil, fmt.Errorf("failed to scan batch result row: %w", err)
+		}
+		results = append(results, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batch result rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetByID retrieves an order by ID
+func (r *OrderRepo) GetByID(ctx context.Context, id string) (*entity.Order, error) {
+	query := `
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE id = $1
+	`
+
+	order := &entity.Order{}
+	err := conn(ctx, r.pool).QueryRow(ctx, query, id).Scan(
+		&order.ID,
+		&order.UserID,
+		&order.Status,
+		&order.Accrual,
+		&order.UploadedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("order not found: %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get order by id: %w", err)
+	}
+
+	return order, nil
+}
+
+// GetByUserID retrieves all orders for a user
+func (r *OrderRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Order, error) {
+	query := `
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE user_id = $1
+		ORDER BY uploaded_at DESC
+	`
+
+	rows, err := conn(ctx, r.pool).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// List retrieves a page of a user's orders using keyset pagination on (uploaded_at, id)
+func (r *OrderRepo) List(ctx context.Context, filter repository.OrderFilter) ([]entity.Order, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if len(filter.Statuses) > 0 {
+		args = append(args, filter.Statuses)
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("uploaded_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("uploaded_at < $%d", len(args)))
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		// The cursor time was read from the column, compare it without time zone conversion
+		args = append(args, filter.After.Time, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(uploaded_at, id) %s ($%d::timestamp, $%d)", comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE %s
+		ORDER BY uploaded_at %s, id %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), direction, direction, len(args))
+
+	rows, err := conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetByStatuses retrieves up to limit orders of any user with one of the statuses, oldest first
+func (r *OrderRepo) GetByStatuses(ctx context.Context, statuses []string, limit int) ([]entity.Order, error) {
+	query := `
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE status = ANY($1)
+		ORDER BY uploaded_at, id
+		LIMIT $2
+	`
+
+	rows, err := conn(ctx, r.pool).Query(ctx, query, statuses, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// CountByStatuses counts the orders of all users with one of the statuses
+func (r *OrderRepo) CountByStatuses(ctx context.Context, statuses []string) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM orders WHERE status = ANY($1)
+	`
+
+	var count int
+	if err := conn(ctx, r.pool).QueryRow(ctx, query, statuses).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	return count, nil
+}
+
+// UpdateStatus sets the status and accrual of an order that still has the status from
+// and reports whether it did, so concurrent updates of the same order apply only once
+func (r *OrderRepo) UpdateStatus(ctx context.Context, order *entity.Order, from string) (bool, error) {
+	query := `
+		UPDATE orders
+		SET status = $1, accrual = $2
+		WHERE id = $3 AND status = $4
+	`
+
+	tag, err := conn(ctx, r.pool).Exec(ctx, query, order.Status, order.Accrual, order.ID, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// CheckExists checks if an order exists and returns the user ID if it does
+func (r *OrderRepo) CheckExists(ctx context.Context, id string) (bool, int64, error) {
+	query := `
+		SELECT user_id FROM orders WHERE id = $1
+	`
+
+	var userID int64
+	err := conn(ctx, r.pool).QueryRow(ctx, query, id).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, 0, nil
+		}
+		return false, 0, fmt.Errorf("failed to check if order exists: %w", err)
+	}
+
+	return true, userID, nil
+}
@@ -0,0 +1,183 @@
+package pgxstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"gophermart/domain/entity"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OutboxRepo implements the OutboxRepository interface
+type OutboxRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewOutboxRepo creates a new OutboxRepo instance
+func NewOutboxRepo(pool *pgxpool.Pool) *OutboxRepo {
+	return &OutboxRepo{pool: pool}
+}
+
+// Add records events in a single batch, within the transaction of ctx if there is one.
+// A batch sent outside a transaction runs in an implicit one, so either all events are recorded or none.
+func (r *OutboxRepo) Add(ctx context.Context, events ...entity.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO outbox_events (event_type, user_id, payload) VALUES ($1, $2, $3)`
+
+	batch := &pgx.Batch{}
+	for _, event := range events {
+		batch.Queue(query, event.Type, event.UserID, string(event.Payload))
+	}
+
+	if err := conn(ctx, r.pool).SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to add outbox events: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimUnpublished leases up to limit of the oldest unpublished events that are not claimed
+// and returns them in the order they were recorded.
+// Concurrent relays skip each other's rows, so an event is only claimed once per lease.
+func (r *OutboxRepo) ClaimUnpublished(ctx context.Context, limit int, lease time.Duration) ([]entity.OutboxEvent, error) {
+	query := `
+		UPDATE outbox_events
+		SET claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE published_at IS NULL AND (claimed_until IS NULL OR claimed_until <= NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, user_id, payload, created_at, attempts, last_error
+	`
+	rows, err := conn(ctx, r.pool).Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events, err := scanOutboxEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(events, func(a, b entity.OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+
+	return events, nil
+}
+
+// MarkPublished marks events as published
+func (r *OutboxRepo) MarkPublished(ctx context.Context, ids []int64) error {
+	query := `UPDATE outbox_events SET published_at = NOW() WHERE id = ANY($1)`
+
+	if _, err := conn(ctx, r.pool).Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("failed to mark outbox events published: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseClaims makes claimed events available to the next claim again
+func (r *OutboxRepo) ReleaseClaims(ctx context.Context, ids []int64) error {
+	query := `UPDATE outbox_events SET claimed_until = NULL WHERE id = ANY($1)`
+
+	if _, err := conn(ctx, r.pool).Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("failed to release outbox event claims: %w", err)
+	}
+
+	return nil
+}
+
+// RecordFailure records a failed attempt to publish an event
+func (r *OutboxRepo) RecordFailure(ctx context.Context, id int64, reason string) error {
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $1 WHERE id = $2`
+
+	if _, err := conn(ctx, r.pool).Exec(ctx, query, reason, id); err != nil {
+		return fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotFannedOut retrieves the oldest events not yet queued for webhooks in the order they were recorded.
+// Within a transaction the rows stay locked until it ends and concurrent workers skip them.
+func (r *OutboxRepo) GetNotFannedOut(ctx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	query := `
+		SELECT id, event_type, user_id, payload, created_at, attempts, last_error
+		FROM outbox_events
+		WHERE fanned_out_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := conn(ctx, r.pool).Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	return scanOutboxEvents(rows)
+}
+
+// MarkFannedOut marks events as queued for webhooks
+func (r *OutboxRepo) MarkFannedOut(ctx context.Context, ids []int64) error {
+	query := `UPDATE outbox_events SET fanned_out_at = NOW() WHERE id = ANY($1)`
+
+	if _, err := conn(ctx, r.pool).Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("failed to mark outbox events fanned out: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePublished deletes events published and queued for webhooks before the given time
+func (r *OutboxRepo) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM outbox_events WHERE published_at < $1 AND fanned_out_at IS NOT NULL`
+
+	result, err := conn(ctx, r.pool).Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// scanOutboxEvents scans all rows selected by ClaimUnpublished and GetNotFannedOut
+func scanOutboxEvents(rows pgx.Rows) ([]entity.OutboxEvent, error) {
+	var events []entity.OutboxEvent
+	for rows.Next() {
+		var e entity.OutboxEvent
+		var payload []byte
+		err := rows.Scan(
+			&e.ID,
+			&e.Type,
+			&e.UserID,
+			&payload,
+			&e.CreatedAt,
+			&e.Attempts,
+			&e.LastError,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event row: %w", err)
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox event rows: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,70 @@
+package pgxstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PasswordResetRepo implements the PasswordResetRepository interface
+type PasswordResetRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewPasswordResetRepo creates a new PasswordResetRepo instance
+func NewPasswordResetRepo(pool *pgxpool.Pool) *PasswordResetRepo {
+	return &PasswordResetRepo{pool: pool}
+}
+
+// Create adds a new password reset token
+func (r *PasswordResetRepo) Create(ctx context.Context, reset *entity.PasswordReset) error {
+	query := `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.pool).QueryRow(ctx, query, reset.UserID, reset.TokenHash, reset.ExpiresAt).Scan(
+		&reset.ID,
+		&reset.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	return nil
+}
+
+// Consume atomically marks an unused, unexpired token as used and returns it
+func (r *PasswordResetRepo) Consume(ctx context.Context, tokenHash string) (*entity.PasswordReset, error) {
+	query := `
+		UPDATE password_resets
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, expires_at, used_at, created_at
+	`
+
+	reset := &entity.PasswordReset{}
+	err := conn(ctx, r.pool).QueryRow(ctx, query, tokenHash).Scan(
+		&reset.ID,
+		&reset.UserID,
+		&reset.TokenHash,
+		&reset.ExpiresAt,
+		&reset.UsedAt,
+		&reset.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("password reset not found: %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to consume password reset: %w", err)
+	}
+
+	return reset, nil
+}
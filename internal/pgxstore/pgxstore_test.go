@@ -0,0 +1,53 @@
+package pgxstore_test
+
+import (
+	"context"
+	"gophermart/domain/repository/repotest"
+	"gophermart/internal/pgxstore"
+	"gophermart/internal/postgres"
+	"gophermart/internal/postgres/pgtest"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool connects to the test database on first use, with the statement cache used in production
+var testPool = sync.OnceValues(func() (*pgxpool.Pool, error) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return pgxstore.NewPool(context.Background(), os.Getenv(pgtest.DSNEnv), postgres.PoolConfig{MaxOpenConns: 10}, logger)
+})
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
+
+// newPgxRepos returns the pgx repositories on the emptied test database
+func newPgxRepos(tb testing.TB) repotest.Repositories {
+	pgtest.Reset(tb)
+
+	pool, err := testPool()
+	if err != nil {
+		tb.Fatalf("failed to connect to the test database: %v", err)
+	}
+
+	return repotest.Repositories{
+		Users:       pgxstore.NewUserRepo(pool),
+		Orders:      pgxstore.NewOrderRepo(pool),
+		Balances:    pgxstore.NewBalanceRepo(pool),
+		Withdrawals: pgxstore.NewWithdrawalRepo(pool),
+		Outbox:      pgxstore.NewOutboxRepo(pool),
+		Transactor:  pgxstore.NewTransactor(pool),
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, newPgxRepos)
+}
+
+func BenchmarkPgx(b *testing.B) {
+	repotest.Bench(b, newPgxRepos)
+}
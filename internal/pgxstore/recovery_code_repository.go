@@ -0,0 +1,58 @@
+package pgxstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gophermart/domain/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RecoveryCodeRepo implements the RecoveryCodeRepository interface
+type RecoveryCodeRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewRecoveryCodeRepo creates a new RecoveryCodeRepo instance
+func NewRecoveryCodeRepo(pool *pgxpool.Pool) *RecoveryCodeRepo {
+	return &RecoveryCodeRepo{pool: pool}
+}
+
+// Replace deletes all recovery codes of a user and stores the given ones in a single batch,
+// which runs in an implicit transaction when ctx has none
+func (r *RecoveryCodeRepo) Replace(ctx context.Context, userID int64, codeHashes []string) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	for _, hash := range codeHashes {
+		batch.Queue(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+	}
+
+	if err := conn(ctx, r.pool).SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+// Consume atomically marks an unused recovery code as used
+func (r *RecoveryCodeRepo) Consume(ctx context.Context, userID int64, codeHash string) error {
+	query := `
+		UPDATE recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+		RETURNING id
+	`
+
+	var id int64
+	err := conn(ctx, r.pool).QueryRow(ctx, query, userID, codeHash).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("recovery code not found: %w", repository.ErrNotFound)
+		}
+		return fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	return nil
+}
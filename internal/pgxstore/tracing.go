@@ -0,0 +1,92 @@
+package pgxstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("gophermart/internal/pgxstore")
+
+// queryTracer records a span for every query, batch and copy run on the pool's connections
+type queryTracer struct{}
+
+var (
+	_ pgx.QueryTracer    = queryTracer{}
+	_ pgx.BatchTracer    = queryTracer{}
+	_ pgx.CopyFromTracer = queryTracer{}
+)
+
+// TraceQueryStart starts a client span named after the SQL verb of the query
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	statement := strings.Join(strings.Fields(data.SQL), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+
+	ctx, _ = startSpan(ctx, strings.ToUpper(operation), statement)
+	return ctx
+}
+
+// TraceQueryEnd ends the span of the query, for QueryRow it ends once the row is scanned
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endSpan(ctx, data.Err)
+}
+
+// TraceBatchStart starts a client span for the whole batch
+func (queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	ctx, span := startSpan(ctx, "BATCH", "")
+	span.SetAttributes(attribute.Int("db.batch.size", data.Batch.Len()))
+	return ctx
+}
+
+// TraceBatchQuery records the failure of a query of the batch
+func (queryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if data.Err != nil {
+		trace.SpanFromContext(ctx).RecordError(data.Err)
+	}
+}
+
+// TraceBatchEnd ends the span of the batch
+func (queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	endSpan(ctx, data.Err)
+}
+
+// TraceCopyFromStart starts a client span for a COPY into the table
+func (queryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	statement := "COPY " + data.TableName.Sanitize() + " (" + strings.Join(data.ColumnNames, ", ") + ") FROM STDIN"
+
+	ctx, _ = startSpan(ctx, "COPY", statement)
+	return ctx
+}
+
+// TraceCopyFromEnd ends the span of the COPY
+func (queryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endSpan(ctx, data.Err)
+}
+
+// startSpan starts a client span for a database operation
+func startSpan(ctx context.Context, operation, statement string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", operation),
+	}
+	if statement != "" {
+		attributes = append(attributes, attribute.String("db.statement", statement))
+	}
+
+	return tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span of ctx, marking it as failed on error
+func endSpan(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -3,26 +3,50 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-// DBConfig contains database configuration
-type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+// uniqueViolation is the SQLSTATE of a unique constraint violation
+const uniqueViolation = "23505"
+
+const (
+	// pingTimeout bounds a single connection attempt
+	pingTimeout = 5 * time.Second
+	// firstRetryDelay is the wait after the first failed attempt, doubled after every further one
+	firstRetryDelay = 500 * time.Millisecond
+	// maxRetryDelay caps the wait between attempts
+	maxRetryDelay = 10 * time.Second
+)
+
+// PoolConfig contains the connection pool settings shared by the Postgres drivers
+type PoolConfig struct {
+	MaxOpenConns     int
+	MaxIdleConns     int // database/sql only, pgx closes idle connections after ConnMaxIdleTime
+	ConnMaxLifetime  time.Duration
+	ConnMaxIdleTime  time.Duration
+	StatementTimeout time.Duration // zero means no timeout
+	ConnectTimeout   time.Duration // how long to retry connecting, zero means a single attempt
 }
 
-// NewDB creates and initializes a new database connection
-func NewDB(cfg DBConfig) (*sql.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
+// NewDB opens a connection pool for the DSN, a URL or keyword/value string passed to lib/pq as is,
+// and waits for the database to be reachable. The schema is managed by Migrator.
+func NewDB(ctx context.Context, dsn string, cfg PoolConfig, logger *slog.Logger) (*sql.DB, error) {
+	// A statement_timeout in the DSN takes precedence
+	if cfg.StatementTimeout > 0 {
+		var err error
+		dsn, err = withDefaultParam(dsn, "statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -30,63 +54,80 @@ func NewDB(cfg DBConfig) (*sql.DB, error) {
 	}
 
 	// Set connection pool parameters
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	// Verify the connection is working
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	if err := WaitReachable(ctx, db.PingContext, cfg.ConnectTimeout, logger); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	// Initialize database schemas
-	if err := initDB(ctx, db); err != nil {
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
-	}
+	logger.Info("Database connected")
 
 	return db, nil
 }
 
-// initDB creates necessary tables if they don't exist
-func initDB(ctx context.Context, db *sql.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			login VARCHAR(255) UNIQUE NOT NULL,
-			password VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE TABLE IF NOT EXISTS orders (
-			id VARCHAR(255) PRIMARY KEY,
-			user_id INTEGER NOT NULL REFERENCES users(id),
-			status VARCHAR(50) NOT NULL,
-			accrual DECIMAL(10, 2) DEFAULT 0,
-			uploaded_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE TABLE IF NOT EXISTS balances (
-			user_id INTEGER PRIMARY KEY REFERENCES users(id),
-			current DECIMAL(10, 2) NOT NULL DEFAULT 0,
-			withdrawn DECIMAL(10, 2) NOT NULL DEFAULT 0,
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE TABLE IF NOT EXISTS withdrawals (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER NOT NULL REFERENCES users(id),
-			order_id VARCHAR(255) NOT NULL,
-			sum DECIMAL(10, 2) NOT NULL,
-			processed_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)`,
+// WaitReachable pings the database until it answers or connectTimeout has passed,
+// backing off exponentially between attempts
+func WaitReachable(ctx context.Context, ping func(ctx context.Context) error, connectTimeout time.Duration, logger *slog.Logger) error {
+	deadline := time.Now().Add(connectTimeout)
+	delay := firstRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		// The last attempt is made at the deadline
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("failed to ping database after %d attempts: %w", attempt, err)
+		}
+		wait := min(delay, remaining)
+
+		logger.Warn("Database not reachable, retrying", "attempt", attempt, "delay", wait, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to ping database: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+
+		delay = min(2*delay, maxRetryDelay)
 	}
+}
 
-	for _, query := range queries {
-		_, err := db.ExecContext(ctx, query)
+// withDefaultParam sets a connection parameter in a URL or keyword/value DSN unless it is already there
+func withDefaultParam(dsn, key, value string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
 		if err != nil {
-			return fmt.Errorf("failed to execute query: %s: %w", query, err)
+			return "", fmt.Errorf("invalid database URI: %w", err)
 		}
+
+		query := u.Query()
+		if !query.Has(key) {
+			query.Set(key, value)
+			u.RawQuery = query.Encode()
+		}
+		return u.String(), nil
 	}
 
-	return nil
+	for _, field := range strings.Fields(dsn) {
+		if name, _, _ := strings.Cut(field, "="); strings.TrimSpace(name) == key {
+			return dsn, nil
+		}
+	}
+	return strings.TrimSpace(dsn + " " + key + "=" + value), nil
+}
+
+// isUniqueViolation reports whether err was caused by a duplicate key
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
 }
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+)
+
+// ExternalIdentityRepo implements the ExternalIdentityRepository interface
+type ExternalIdentityRepo struct {
+	db *sql.DB
+}
+
+// NewExternalIdentityRepo creates a new ExternalIdentityRepo instance
+func NewExternalIdentityRepo(db *sql.DB) *ExternalIdentityRepo {
+	return &ExternalIdentityRepo{db: db}
+}
+
+// Create adds a new external identity
+func (r *ExternalIdentityRepo) Create(ctx context.Context, identity *entity.ExternalIdentity) error {
+	query := `
+		INSERT INTO external_identities (user_id, issuer, subject)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, identity.UserID, identity.Issuer, identity.Subject).Scan(
+		&identity.ID,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create external identity: %w", err)
+	}
+
+	return nil
+}
+
+// GetByIssuerSubject retrieves an external identity by issuer and subject
+func (r *ExternalIdentityRepo) GetByIssuerSubject(ctx context.Context, issuer, subject string) (*entity.ExternalIdentity, error) {
+	query := `
+		SELECT id, user_id, issuer, subject, created_at
+		FROM external_identities
+		WHERE issuer = $1 AND subject = $2
+	`
+
+	identity := &entity.ExternalIdentity{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, issuer, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Issuer,
+		&identity.Subject,
+		&identity.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("external identity not found: %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get external identity: %w", err)
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"log/slog"
+
+	"gophermart/internal/migrate"
+)
+
+// migrationsLockKey identifies the advisory lock held while migrating, so concurrent replicas migrate one at a time
+const migrationsLockKey int64 = 0x676f706865726d61
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// dialect migrates PostgreSQL holding a session advisory lock
+var dialect = migrate.Dialect{
+	Lock: func(ctx context.Context, conn *sql.Conn) (func() error, error) {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockKey); err != nil {
+			return nil, err
+		}
+
+		return func() error {
+			// The connection goes back to the pool, so the lock must be released even when ctx is done
+			_, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationsLockKey)
+			return err
+		}, nil
+	},
+	TableExists: `SELECT to_regclass('schema_migrations') IS NOT NULL`,
+	CreateTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`,
+}
+
+// NewMigrator creates a new Migrator with the embedded PostgreSQL migrations
+func NewMigrator(db *sql.DB, logger *slog.Logger) (*migrate.Migrator, error) {
+	return migrate.New(db, migrationFiles, dialect, logger)
+}
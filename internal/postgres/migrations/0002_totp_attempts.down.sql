@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS totp_attempted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_failures;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
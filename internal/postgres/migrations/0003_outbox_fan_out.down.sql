@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_outbox_events_not_fanned_out;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS fanned_out_at;
@@ -0,0 +1,5 @@
+-- Webhook deliveries are queued from the outbox. Events recorded before were already queued from the event bus.
+
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS fanned_out_at TIMESTAMPTZ;
+UPDATE outbox_events SET fanned_out_at = created_at WHERE fanned_out_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_not_fanned_out ON outbox_events (id) WHERE fanned_out_at IS NULL;
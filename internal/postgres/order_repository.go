@@ -6,6 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
 // OrderRepo implements the OrderRepository interface
@@ -26,14 +30,58 @@ func (r *OrderRepo) Create(ctx context.Context, order *entity.Order) error {
 		RETURNING uploaded_at
 	`
 
-	err := r.db.QueryRowContext(ctx, query, order.ID, order.UserID, order.Status).Scan(&order.UploadedAt)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, order.ID, order.UserID, order.Status).Scan(&order.UploadedAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("order already exists: %w", repository.ErrAlreadyExists)
+		}
 		return fmt.Errorf("failed to create order: %w", err)
 	}
 
 	return nil
 }
 
+// CreateBatch inserts new orders in a single statement.
+// For orders that already exist it reports the owner instead of inserting them.
+func (r *OrderRepo) CreateBatch(ctx context.Context, userID int64, ids []string) ([]repository.BatchInsertResult, error) {
+	// The outer SELECT sees the table as it was before the insert, so it only finds pre-existing orders
+	query := `
+		WITH input AS (
+			SELECT DISTINCT unnest($1::text[]) AS id
+		), inserted AS (
+			INSERT INTO orders (id, user_id, status)
+			SELECT id, $2, $3 FROM input
+			ON CONFLICT (id) DO NOTHING
+			RETURNING id
+		)
+		SELECT input.id, inserted.id IS NOT NULL, COALESCE(existing.user_id, 0)
+		FROM input
+		LEFT JOIN inserted ON inserted.id = input.id
+		LEFT JOIN orders existing ON existing.id = input.id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pq.Array(ids), userID, entity.StatusNew)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create orders: %w", err)
+	}
+	defer rows.Close()
+
+	var results []repository.BatchInsertResult
+	for rows.Next() {
+		var res repository.BatchInsertResult
+		if err := rows.Scan(&res.ID, &res.Inserted, &res.OwnerID); err != nil {
+			return nil, fmt.Errorf("failed to scan batch result row: %w", err)
+		}
+		results = append(results, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batch result rows: %w", err)
+	}
+
+	return results, nil
+}
+
 // GetByID retrieves an order by ID
 func (r *OrderRepo) GetByID(ctx context.Context, id string) (*entity.Order, error) {
 	query := `
@@ -43,7 +91,7 @@ func (r *OrderRepo) GetByID(ctx context.Context, id string) (*entity.Order, erro
 	`
 
 	order := &entity.Order{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&order.ID,
 		&order.UserID,
 		&order.Status,
@@ -53,7 +101,7 @@ func (r *OrderRepo) GetByID(ctx context.Context, id string) (*entity.Order, erro
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("order not found: %w", err)
+			return nil, fmt.Errorf("order not found: %w", repository.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get order by id: %w", err)
 	}
@@ -70,7 +118,7 @@ func (r *OrderRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Ord
 		ORDER BY uploaded_at DESC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query orders: %w", err)
 	}
@@ -99,20 +147,146 @@ func (r *OrderRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Ord
 	return orders, nil
 }
 
-// Update updates an existing order
-func (r *OrderRepo) Update(ctx context.Context, order *entity.Order) error {
+// List retrieves a page of a user's orders using keyset pagination on (uploaded_at, id)
+func (r *OrderRepo) List(ctx context.Context, filter repository.OrderFilter) ([]entity.Order, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if len(filter.Statuses) > 0 {
+		args = append(args, pq.Array(filter.Statuses))
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("uploaded_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("uploaded_at < $%d", len(args)))
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		// The cursor time was read from the column, compare it without time zone conversion
+		args = append(args, filter.After.Time, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(uploaded_at, id) %s ($%d::timestamp, $%d)", comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE %s
+		ORDER BY uploaded_at %s, id %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), direction, direction, len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetByStatuses retrieves up to limit orders of any user with one of the statuses, oldest first
+func (r *OrderRepo) GetByStatuses(ctx context.Context, statuses []string, limit int) ([]entity.Order, error) {
+	query := `
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE status = ANY($1)
+		ORDER BY uploaded_at, id
+		LIMIT $2
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pq.Array(statuses), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// CountByStatuses counts the orders of all users with one of the statuses
+func (r *OrderRepo) CountByStatuses(ctx context.Context, statuses []string) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM orders WHERE status = ANY($1)
+	`
+
+	var count int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, pq.Array(statuses)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	return count, nil
+}
+
+// UpdateStatus sets the status and accrual of an order that still has the status from
+// and reports whether it did, so concurrent updates of the same order apply only once
+func (r *OrderRepo) UpdateStatus(ctx context.Context, order *entity.Order, from string) (bool, error) {
 	query := `
 		UPDATE orders
 		SET status = $1, accrual = $2
-		WHERE id = $3
+		WHERE id = $3 AND status = $4
 	`
 
-	_, err := r.db.ExecContext(ctx, query, order.Status, order.Accrual, order.ID)
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, order.Status, order.Accrual, order.ID, from)
 	if err != nil {
-		return fmt.Errorf("failed to update order: %w", err)
+		return false, fmt.Errorf("failed to update order: %w", err)
 	}
 
-	return nil
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
 }
 
 // CheckExists checks if an order exists and returns the user ID if it does
@@ -122,7 +296,7 @@ func (r *OrderRepo) CheckExists(ctx context.Context, id string) (bool, int64, er
 	`
 
 	var userID int64
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&userID)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(&userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, 0, nil
@@ -0,0 +1,98 @@
+// Package pgtest prepares the Postgres database of TEST_DATABASE_URI for the tests of the Postgres
+// backends. Its tables are emptied before every test, so it must be a database the tests may own:
+//
+//	func TestMain(m *testing.M) { os.Exit(pgtest.Main(m)) }
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(tb testing.TB) repotest.Repositories {
+//			db := pgtest.Reset(tb)
+//			return repotest.Repositories{Users: postgres.NewUserRepo(db), ...}
+//		})
+//	}
+//
+// Without TEST_DATABASE_URI the tests calling Reset are skipped.
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gophermart/internal/postgres"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+// DSNEnv is the environment variable with the DSN of the test database
+const DSNEnv = "TEST_DATABASE_URI"
+
+// lockKey identifies the advisory lock held while the tests of a package run.
+// go test runs packages in parallel and the Postgres backends share the test database.
+const lockKey = 0x676f70686572
+
+// db is the test database, nil when DSNEnv is not set
+var db *sql.DB
+
+// Main migrates the test database and runs the tests while no other package uses it,
+// it returns the exit code for os.Exit
+func Main(m *testing.M) int {
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		return m.Run()
+	}
+
+	if err := open(dsn); err != nil {
+		fmt.Fprintf(os.Stderr, "pgtest: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	return m.Run()
+}
+
+// Reset empties the tables of the test database and returns it, the test is skipped when DSNEnv is not set
+func Reset(tb testing.TB) *sql.DB {
+	tb.Helper()
+
+	if db == nil {
+		tb.Skipf("%s is not set", DSNEnv)
+	}
+
+	if _, err := db.ExecContext(context.Background(), `TRUNCATE users, outbox_events RESTART IDENTITY CASCADE`); err != nil {
+		tb.Fatalf("failed to empty the test database: %v", err)
+	}
+
+	return db
+}
+
+// open connects to the test database, waits for the lock and applies the migrations
+func open(dsn string) error {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var err error
+	db, err = postgres.NewDB(ctx, dsn, postgres.PoolConfig{MaxOpenConns: 10, MaxIdleConns: 10}, logger)
+	if err != nil {
+		return err
+	}
+
+	// The lock belongs to the session of one connection, which stays reserved until the process exits
+	lock, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to reserve connection: %w", err)
+	}
+	if _, err := lock.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to lock the test database: %w", err)
+	}
+
+	migrator, err := postgres.NewMigrator(db, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to migrate the test database: %w", err)
+	}
+
+	return nil
+}
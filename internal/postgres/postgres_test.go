@@ -0,0 +1,35 @@
+package postgres_test
+
+import (
+	"gophermart/domain/repository/repotest"
+	"gophermart/internal/postgres"
+	"gophermart/internal/postgres/pgtest"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
+
+// newPqRepos returns the lib/pq repositories on the emptied test database
+func newPqRepos(tb testing.TB) repotest.Repositories {
+	db := pgtest.Reset(tb)
+
+	return repotest.Repositories{
+		Users:       postgres.NewUserRepo(db),
+		Orders:      postgres.NewOrderRepo(db),
+		Balances:    postgres.NewBalanceRepo(db),
+		Withdrawals: postgres.NewWithdrawalRepo(db),
+		Outbox:      postgres.NewOutboxRepo(db),
+		Transactor:  postgres.NewTransactor(db),
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, newPqRepos)
+}
+
+func BenchmarkPq(b *testing.B) {
+	repotest.Bench(b, newPqRepos)
+}
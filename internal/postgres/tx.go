@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// txKey is the context key of the transaction started by Transactor
+type txKey struct{}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Transactor implements the Transactor interface
+type Transactor struct {
+	db *sql.DB
+}
+
+// NewTransactor creates a new Transactor instance
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// WithinTransaction runs fn within a transaction, joining the transaction of ctx if there is one
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return inTx(ctx, t.db, func(tx querier) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction of ctx, or the pool when there is none
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(querier); ok {
+		return tx
+	}
+	return traced(db)
+}
+
+// inTx runs fn within the transaction of ctx, or within a new transaction when there is none
+func inTx(ctx context.Context, db *sql.DB, fn func(tx querier) error) error {
+	if tx, ok := ctx.Value(txKey{}).(querier); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(traced(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"strings"
+	"time"
 )
 
 // UserRepo implements the UserRepository interface
@@ -21,13 +24,16 @@ func NewUserRepo(db *sql.DB) *UserRepo {
 // Create adds a new user to the database
 func (r *UserRepo) Create(ctx context.Context, user *entity.User) error {
 	query := `
-		INSERT INTO users (login, password)
-		VALUES ($1, $2)
+		INSERT INTO users (login, password, role)
+		VALUES ($1, $2, $3)
 		RETURNING id, created_at
 	`
 
-	err := r.db.QueryRowContext(ctx, query, user.Login, user.Password).Scan(&user.ID, &user.CreatedAt)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, user.Login, user.Password, user.Role).Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("login already taken: %w", repository.ErrAlreadyExists)
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -37,22 +43,27 @@ func (r *UserRepo) Create(ctx context.Context, user *entity.User) error {
 // GetByLogin retrieves a user by login
 func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*entity.User, error) {
 	query := `
-		SELECT id, login, password, created_at
+		SELECT id, login, password, role, token_version, totp_secret, totp_enabled, blocked, created_at
 		FROM users
 		WHERE login = $1
 	`
 
 	user := &entity.User{}
-	err := r.db.QueryRowContext(ctx, query, login).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, login).Scan(
 		&user.ID,
 		&user.Login,
 		&user.Password,
+		&user.Role,
+		&user.TokenVersion,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.Blocked,
 		&user.CreatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found: %w", err)
+			return nil, fmt.Errorf("user not found: %w", repository.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user by login: %w", err)
 	}
@@ -63,25 +74,187 @@ func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*entity.User,
 // GetByID retrieves a user by ID
 func (r *UserRepo) GetByID(ctx context.Context, id int64) (*entity.User, error) {
 	query := `
-		SELECT id, login, password, created_at
+		SELECT id, login, password, role, token_version, totp_secret, totp_enabled, blocked, created_at
 		FROM users
 		WHERE id = $1
 	`
 
 	user := &entity.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Login,
 		&user.Password,
+		&user.Role,
+		&user.TokenVersion,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.Blocked,
 		&user.CreatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found: %w", err)
+			return nil, fmt.Errorf("user not found: %w", repository.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
 	return user, nil
 }
+
+// UpdatePassword stores a new password hash and increments the token version
+func (r *UserRepo) UpdatePassword(ctx context.Context, user *entity.User) error {
+	query := `
+		UPDATE users
+		SET password = $1, token_version = token_version + 1
+		WHERE id = $2
+		RETURNING token_version
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, user.Password, user.ID).Scan(&user.TokenVersion)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %w", repository.ErrNotFound)
+		}
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTOTP stores the user's TOTP secret and enabled flag
+func (r *UserRepo) UpdateTOTP(ctx context.Context, user *entity.User) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_enabled = $2
+		WHERE id = $3
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, user.TOTPSecret, user.TOTPEnabled, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update TOTP settings: %w", err)
+	}
+
+	return nil
+}
+
+// BeginTOTPAttempt counts a second factor attempt unless the user is locked out
+func (r *UserRepo) BeginTOTPAttempt(ctx context.Context, userID int64, maxFailures int, lockout time.Duration) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_failures = CASE WHEN totp_failures >= $2 THEN 1 ELSE totp_failures + 1 END,
+			totp_attempted_at = NOW()
+		WHERE id = $1 AND (totp_failures < $2 OR totp_attempted_at <= NOW() - make_interval(secs => $3))
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, userID, maxFailures, lockout.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP attempt: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// AcceptTOTP clears the attempt count and stores the step of the accepted TOTP code
+func (r *UserRepo) AcceptTOTP(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_failures = 0, totp_last_step = GREATEST(totp_last_step, $2)
+		WHERE id = $1 AND ($2 = 0 OR totp_last_step < $2)
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept TOTP code: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// UpdateRole stores the user's role
+func (r *UserRepo) UpdateRole(ctx context.Context, user *entity.User) error {
+	query := `
+		UPDATE users
+		SET role = $1
+		WHERE id = $2
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, user.Role, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateBlocked stores whether the user is blocked
+func (r *UserRepo) UpdateBlocked(ctx context.Context, user *entity.User) error {
+	query := `
+		UPDATE users
+		SET blocked = $1
+		WHERE id = $2
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, user.Blocked, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update blocked flag: %w", err)
+	}
+
+	return nil
+}
+
+// SearchByLogin retrieves users whose login contains the query
+func (r *UserRepo) SearchByLogin(ctx context.Context, query string, limit int) ([]entity.User, error) {
+	sqlQuery := `
+		SELECT id, login, password, role, token_version, totp_secret, totp_enabled, blocked, created_at
+		FROM users
+		WHERE login ILIKE '%' || $1 || '%' ESCAPE '\'
+		ORDER BY login
+		LIMIT $2
+	`
+
+	// Escape LIKE wildcards so the query is matched literally
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, sqlQuery, escaped, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		var user entity.User
+		err := rows.Scan(
+			&user.ID,
+			&user.Login,
+			&user.Password,
+			&user.Role,
+			&user.TokenVersion,
+			&user.TOTPSecret,
+			&user.TOTPEnabled,
+			&user.Blocked,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	return users, nil
+}
@@ -0,0 +1,297 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// deliveryColumns lists the columns scanned by scanDelivery
+const deliveryColumns = `id, subscription_id, event_type, payload, status, attempts, next_attempt_at,
+	last_error, last_status_code, created_at, delivered_at`
+
+// WebhookRepo implements the WebhookRepository interface
+type WebhookRepo struct {
+	db *sql.DB
+}
+
+// NewWebhookRepo creates a new WebhookRepo instance
+func NewWebhookRepo(db *sql.DB) *WebhookRepo {
+	return &WebhookRepo{db: db}
+}
+
+// CreateSubscription inserts a new webhook subscription
+func (r *WebhookRepo) CreateSubscription(ctx context.Context, subscription *entity.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (user_id, url, event_types, secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		subscription.UserID,
+		subscription.URL,
+		pq.Array(subscription.EventTypes),
+		subscription.Secret,
+	).Scan(&subscription.ID, &subscription.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+// GetSubscription retrieves a webhook subscription by ID
+func (r *WebhookRepo) GetSubscription(ctx context.Context, id int64) (*entity.WebhookSubscription, error) {
+	query := `
+		SELECT id, user_id, url, event_types, secret, created_at
+		FROM webhook_subscriptions
+		WHERE id = $1
+	`
+
+	subscription := &entity.WebhookSubscription{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&subscription.ID,
+		&subscription.UserID,
+		&subscription.URL,
+		pq.Array(&subscription.EventTypes),
+		&subscription.Secret,
+		&subscription.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook subscription not found: %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+
+	return subscription, nil
+}
+
+// GetSubscriptionsByUserID retrieves all webhook subscriptions of a user
+func (r *WebhookRepo) GetSubscriptionsByUserID(ctx context.Context, userID int64) ([]entity.WebhookSubscription, error) {
+	query := `
+		SELECT id, user_id, url, event_types, secret, created_at
+		FROM webhook_subscriptions
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []entity.WebhookSubscription
+	for rows.Next() {
+		var s entity.WebhookSubscription
+		err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.URL,
+			pq.Array(&s.EventTypes),
+			&s.Secret,
+			&s.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription row: %w", err)
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook subscription rows: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription deletes a webhook subscription together with its deliveries
+func (r *WebhookRepo) DeleteSubscription(ctx context.Context, id int64) error {
+	query := `DELETE FROM webhook_subscriptions WHERE id = $1`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("webhook subscription not found: %w", repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Enqueue queues a delivery of the payload to every subscription of the user to the event type
+func (r *WebhookRepo) Enqueue(ctx context.Context, userID int64, eventType string, payload []byte) (int64, error) {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_type, payload, status)
+		SELECT id, $2, $3, $4
+		FROM webhook_subscriptions
+		WHERE user_id = $1 AND $2 = ANY(event_types)
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, userID, eventType, payload, entity.DeliveryPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// ClaimDue leases up to limit pending deliveries that are due by postponing them by lease.
+// Concurrent callers skip each other's rows, so a delivery is only claimed once per lease.
+func (r *WebhookRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]entity.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = NOW() + make_interval(secs => $3)
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, entity.DeliveryPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows)
+}
+
+// GetDelivery retrieves a webhook delivery by ID
+func (r *WebhookRepo) GetDelivery(ctx context.Context, id int64) (*entity.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	delivery, err := scanDelivery(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook delivery not found: %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// GetDeliveriesBySubscriptionID retrieves the most recent deliveries of a subscription
+func (r *WebhookRepo) GetDeliveriesBySubscriptionID(
+	ctx context.Context,
+	subscriptionID int64,
+	limit int,
+) ([]entity.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows)
+}
+
+// UpdateDelivery stores the outcome of a delivery attempt
+func (r *WebhookRepo) UpdateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, last_status_code = $5, delivered_at = $6
+		WHERE id = $7
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastError,
+		delivery.LastStatusCode,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("webhook delivery not found: %w", repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+// scanDelivery scans a row selected with deliveryColumns
+func scanDelivery(row interface{ Scan(dest ...any) error }) (*entity.WebhookDelivery, error) {
+	delivery := &entity.WebhookDelivery{}
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.LastStatusCode,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return delivery, nil
+}
+
+// scanDeliveries scans all rows selected with deliveryColumns
+func scanDeliveries(rows *sql.Rows) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery row: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook delivery rows: %w", err)
+	}
+
+	return deliveries, nil
+}
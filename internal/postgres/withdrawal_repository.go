@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"strings"
 )
 
 // WithdrawalRepo implements the WithdrawalRepository interface
@@ -25,7 +27,7 @@ func (r *WithdrawalRepo) Create(ctx context.Context, withdrawal *entity.Withdraw
 		RETURNING id, processed_at
 	`
 
-	err := r.db.QueryRowContext(ctx, query, withdrawal.UserID, withdrawal.OrderID, withdrawal.Sum).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, withdrawal.UserID, withdrawal.OrderID, withdrawal.Sum).Scan(
 		&withdrawal.ID,
 		&withdrawal.ProcessedAt,
 	)
@@ -45,7 +47,70 @@ func (r *WithdrawalRepo) GetByUserID(ctx context.Context, userID int64) ([]entit
 		ORDER BY processed_at DESC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query withdrawals: %w", err)
+	}
+	defer rows.Close()
+
+	var withdrawals []entity.Withdrawal
+	for rows.Next() {
+		var w entity.Withdrawal
+		err := rows.Scan(
+			&w.ID,
+			&w.UserID,
+			&w.OrderID,
+			&w.Sum,
+			&w.ProcessedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan withdrawal row: %w", err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating withdrawal rows: %w", err)
+	}
+
+	return withdrawals, nil
+}
+
+// List retrieves a page of a user's withdrawals using keyset pagination on (processed_at, id)
+func (r *WithdrawalRepo) List(ctx context.Context, filter repository.WithdrawalFilter) ([]entity.Withdrawal, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("processed_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("processed_at < $%d", len(args)))
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		// The cursor time was read from the column, compare it without time zone conversion
+		args = append(args, filter.After.Time, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(processed_at, id) %s ($%d::timestamp, $%d::integer)", comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, order_id, sum, processed_at
+		FROM withdrawals
+		WHERE %s
+		ORDER BY processed_at %s, id %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), direction, direction, len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query withdrawals: %w", err)
 	}
@@ -0,0 +1,27 @@
+package publish
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"log/slog"
+)
+
+// LogPublisher writes events to the logger, useful for local development
+type LogPublisher struct {
+	logger *slog.Logger
+}
+
+// NewLogPublisher creates a new LogPublisher
+func NewLogPublisher(logger *slog.Logger) *LogPublisher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogPublisher{logger: logger}
+}
+
+// Publish logs the event
+func (p *LogPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	p.logger.InfoContext(ctx, "Domain event published",
+		"event_id", event.ID, "event_type", event.Type, "user_id", event.UserID, "data", string(event.Payload))
+	return nil
+}
@@ -0,0 +1,48 @@
+package publish
+
+import (
+	"context"
+	"gophermart/domain/entity"
+	"sync"
+)
+
+// MemoryPublisher keeps published events in memory, for tests
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []entity.OutboxEvent
+	err    error
+}
+
+// NewMemoryPublisher creates a new MemoryPublisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish stores the event, or fails with the error set by FailWith
+func (p *MemoryPublisher) Publish(ctx context.Context, event entity.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	p.events = append(p.events, event)
+	return nil
+}
+
+// FailWith makes subsequent calls to Publish fail with err, nil restores success
+func (p *MemoryPublisher) FailWith(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
+
+// Events returns a copy of the events published so far
+func (p *MemoryPublisher) Events() []entity.OutboxEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]entity.OutboxEvent(nil), p.events...)
+}
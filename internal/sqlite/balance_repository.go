@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+)
+
+// BalanceRepo implements the BalanceRepository interface
+type BalanceRepo struct {
+	db *sql.DB
+}
+
+// NewBalanceRepo creates a new BalanceRepo instance
+func NewBalanceRepo(db *sql.DB) *BalanceRepo {
+	return &BalanceRepo{db: db}
+}
+
+// GetOrCreate retrieves or creates a balance record for a user
+func (r *BalanceRepo) GetOrCreate(ctx context.Context, userID int64) (*entity.Balance, error) {
+	// Try to get existing balance
+	query := `
+		SELECT user_id, current, withdrawn, updated_at
+		FROM balances
+		WHERE user_id = $1
+	`
+
+	balance := &entity.Balance{UserID: userID}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID).Scan(
+		&balance.UserID,
+		&balance.Current,
+		&balance.Withdrawn,
+		&balance.UpdatedAt,
+	)
+
+	if err == nil {
+		return balance, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	// Create new balance if not exists
+	insertQuery := `
+		INSERT INTO balances (user_id, current, withdrawn, updated_at)
+		VALUES ($1, 0, 0, $2)
+		RETURNING current, withdrawn, updated_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, insertQuery, userID, now()).Scan(
+		&balance.Current,
+		&balance.Withdrawn,
+		&balance.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// UpdateBalance updates a user's balance
+func (r *BalanceRepo) UpdateBalance(ctx context.Context, userID int64, amount float64, isWithdrawal bool) error {
+	return inTx(ctx, r.db, func(tx querier) error {
+		// Make sure the row exists so that a first accrual or withdrawal finds it
+		updatedAt := now()
+		query := `INSERT INTO balances (user_id, updated_at) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, userID, updatedAt); err != nil {
+			return fmt.Errorf("failed to create balance: %w", err)
+		}
+
+		// The transaction holds the write lock, so the balance cannot change before the update
+		var current, withdrawn float64
+		err := tx.QueryRowContext(ctx, `SELECT current, withdrawn FROM balances WHERE user_id = $1`, userID).Scan(&current, &withdrawn)
+		if err != nil {
+			return fmt.Errorf("failed to get balance: %w", err)
+		}
+
+		// Check sufficient funds for withdrawal
+		if isWithdrawal && current < amount {
+			return repository.ErrInsufficientFunds
+		}
+
+		// Update based on operation type
+		var updateQuery string
+		if isWithdrawal {
+			updateQuery = `
+				UPDATE balances
+				SET current = current - $1, withdrawn = withdrawn + $1, updated_at = $2
+				WHERE user_id = $3
+			`
+		} else {
+			updateQuery = `
+				UPDATE balances
+				SET current = current + $1, updated_at = $2
+				WHERE user_id = $3
+			`
+		}
+
+		_, err = tx.ExecContext(ctx, updateQuery, amount, updatedAt, userID)
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Adjust applies a signed manual adjustment to a user's balance and records it
+func (r *BalanceRepo) Adjust(ctx context.Context, adjustment *entity.BalanceAdjustment) error {
+	return inTx(ctx, r.db, func(tx querier) error {
+		// The transaction holds the write lock, so the balance cannot change before the update
+		var current float64
+		err := tx.QueryRowContext(ctx, `SELECT current FROM balances WHERE user_id = $1`, adjustment.UserID).Scan(&current)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("balance not found: %w", repository.ErrNotFound)
+			}
+			return fmt.Errorf("failed to get balance: %w", err)
+		}
+
+		// Debits must not make the balance negative
+		if current+adjustment.Amount < 0 {
+			return repository.ErrInsufficientFunds
+		}
+
+		updateQuery := `
+			UPDATE balances
+			SET current = current + $1, updated_at = $2
+			WHERE user_id = $3
+		`
+
+		updatedAt := now()
+		_, err = tx.ExecContext(ctx, updateQuery, adjustment.Amount, updatedAt, adjustment.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		insertQuery := `
+			INSERT INTO balance_adjustments (user_id, admin_id, amount, reason, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at
+		`
+
+		err = tx.QueryRowContext(ctx, insertQuery, adjustment.UserID, adjustment.AdminID, adjustment.Amount, adjustment.Reason, updatedAt).Scan(
+			&adjustment.ID,
+			&adjustment.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record adjustment: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetAdjustmentsByUserID retrieves all manual adjustments of a user's balance
+func (r *BalanceRepo) GetAdjustmentsByUserID(ctx context.Context, userID int64) ([]entity.BalanceAdjustment, error) {
+	query := `
+		SELECT id, user_id, admin_id, amount, reason, created_at
+		FROM balance_adjustments
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	var adjustments []entity.BalanceAdjustment
+	for rows.Next() {
+		var a entity.BalanceAdjustment
+		err := rows.Scan(
+			&a.ID,
+			&a.UserID,
+			&a.AdminID,
+			&a.Amount,
+			&a.Reason,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan adjustment row: %w", err)
+		}
+		adjustments = append(adjustments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating adjustment rows: %w", err)
+	}
+
+	return adjustments, nil
+}
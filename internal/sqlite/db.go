@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// NewDB opens the database file at path, creating it if needed, the schema is managed by the Migrator.
+// Transactions take the write lock when they begin and writers wait up to five seconds for it.
+// The params, such as _pragma=cache_size(-20000), are passed to the driver after its own.
+func NewDB(path string, params url.Values, logger *slog.Logger) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn(path, params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Verify the database can be opened
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	logger.Info("Database connected", "path", path)
+
+	return db, nil
+}
+
+// dsn returns the data source name of the database file at path with the params merged into the ones
+// the repositories rely on. Pragmas of params run after the defaults, so they can override busy_timeout.
+func dsn(path string, params url.Values) string {
+	query := url.Values{
+		"_txlock":      {"immediate"},
+		"_time_format": {"sqlite"},
+		"_pragma":      {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"},
+	}
+	for key, values := range params {
+		switch key {
+		case "_txlock", "_time_format":
+			// Serialized writers and the text time format are required by the repositories
+		default:
+			query[key] = append(query[key], values...)
+		}
+	}
+
+	return "file:" + path + "?" + query.Encode()
+}
+
+// now returns the current time in UTC, timestamps are stored as text and only compare correctly in one time zone
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// utc converts an optional time to UTC before it is stored
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// isUniqueViolation reports whether err was caused by a duplicate key
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
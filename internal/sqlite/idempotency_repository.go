@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+)
+
+// IdempotencyRepo implements the IdempotencyRepository interface
+type IdempotencyRepo struct {
+	db *sql.DB
+}
+
+// NewIdempotencyRepo creates a new IdempotencyRepo instance
+func NewIdempotencyRepo(db *sql.DB) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db}
+}
+
+// Create inserts an in-progress record, it reports false if the key is already taken
+func (r *IdempotencyRepo) Create(ctx context.Context, record *entity.IdempotencyRecord) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, record.UserID, record.Key, record.RequestHash, record.ExpiresAt.UTC(), now()).Scan(
+		&record.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create idempotency record: %w", err)
+	}
+
+	return true, nil
+}
+
+// Get retrieves an idempotency record by user and key
+func (r *IdempotencyRepo) Get(ctx context.Context, userID int64, key string) (*entity.IdempotencyRecord, error) {
+	query := `
+		SELECT user_id, key, request_hash, completed, status_code, headers, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+
+	record := &entity.IdempotencyRecord{}
+	var headers []byte
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID, key).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&record.Completed,
+		&record.StatusCode,
+		&headers,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("idempotency record not found: %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+
+	if err := json.Unmarshal(headers, &record.Headers); err != nil {
+		return nil, fmt.Errorf("failed to decode stored headers: %w", err)
+	}
+
+	return record, nil
+}
+
+// Complete stores the response of a record
+func (r *IdempotencyRepo) Complete(ctx context.Context, record *entity.IdempotencyRecord) error {
+	headers, err := json.Marshal(record.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET completed = TRUE, status_code = $1, headers = $2, body = $3
+		WHERE user_id = $4 AND key = $5
+	`
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, query, record.StatusCode, string(headers), record.Body, record.UserID, record.Key)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency record: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an idempotency record
+func (r *IdempotencyRepo) Delete(ctx context.Context, userID int64, key string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency record: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes all expired idempotency records
+func (r *IdempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency records: %w", err)
+	}
+
+	return result.RowsAffected()
+}
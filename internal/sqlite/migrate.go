@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"log/slog"
+
+	"gophermart/internal/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// dialect migrates SQLite without a lock, every migration runs in an immediate transaction
+// and a database file is only served by one process
+var dialect = migrate.Dialect{
+	TableExists: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`,
+	CreateTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`,
+}
+
+// NewMigrator creates a new Migrator with the embedded SQLite migrations
+func NewMigrator(db *sql.DB, logger *slog.Logger) (*migrate.Migrator, error) {
+	return migrate.New(db, migrationFiles, dialect, logger)
+}
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS balance_adjustments;
+DROP TABLE IF EXISTS external_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS withdrawals;
+DROP TABLE IF EXISTS balances;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS users;
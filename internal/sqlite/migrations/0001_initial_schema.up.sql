@@ -0,0 +1,132 @@
+-- Baseline schema, equivalent to the PostgreSQL one. Timestamps are written by the application
+-- in UTC and amounts are stored as REAL.
+
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	login TEXT UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	token_version INTEGER NOT NULL DEFAULT 0,
+	totp_secret TEXT NOT NULL DEFAULT '',
+	totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	role TEXT NOT NULL DEFAULT 'user',
+	blocked BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE orders (
+	id TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	status TEXT NOT NULL,
+	accrual REAL DEFAULT 0,
+	uploaded_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_orders_user_uploaded ON orders (user_id, uploaded_at, id);
+
+CREATE TABLE balances (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id),
+	current REAL NOT NULL DEFAULT 0,
+	withdrawn REAL NOT NULL DEFAULT 0,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE withdrawals (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	order_id TEXT NOT NULL,
+	sum REAL NOT NULL,
+	processed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_withdrawals_user_processed ON withdrawals (user_id, processed_at, id);
+
+CREATE TABLE password_resets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	token_hash TEXT UNIQUE NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE recovery_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP,
+	UNIQUE (user_id, code_hash)
+);
+
+CREATE TABLE external_identities (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	issuer TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	UNIQUE (issuer, subject)
+);
+
+CREATE TABLE balance_adjustments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	admin_id INTEGER NOT NULL REFERENCES users(id),
+	amount REAL NOT NULL,
+	reason TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE idempotency_keys (
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	key TEXT NOT NULL,
+	request_hash TEXT NOT NULL,
+	completed BOOLEAN NOT NULL DEFAULT FALSE,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	headers TEXT NOT NULL DEFAULT '{}',
+	body BLOB,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, key)
+);
+
+-- event_types holds a JSON array of strings
+CREATE TABLE webhook_subscriptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	url TEXT NOT NULL,
+	event_types TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_webhook_subscriptions_user ON webhook_subscriptions (user_id);
+
+CREATE TABLE webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+	event_type TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	last_status_code INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL,
+	delivered_at TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id, id);
+
+CREATE TABLE outbox_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_type TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	payload TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	published_at TIMESTAMP,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_outbox_events_unpublished ON outbox_events (id) WHERE published_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN totp_attempted_at;
+ALTER TABLE users DROP COLUMN totp_failures;
+ALTER TABLE users DROP COLUMN totp_last_step;
@@ -0,0 +1,5 @@
+-- Second factor attempt limiting and TOTP replay protection
+
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_attempted_at TIMESTAMP;
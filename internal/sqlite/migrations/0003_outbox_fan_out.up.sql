@@ -0,0 +1,5 @@
+-- Webhook deliveries are queued from the outbox. Events recorded before were already queued from the event bus.
+
+ALTER TABLE outbox_events ADD COLUMN fanned_out_at TIMESTAMP;
+UPDATE outbox_events SET fanned_out_at = created_at;
+CREATE INDEX idx_outbox_events_not_fanned_out ON outbox_events (id) WHERE fanned_out_at IS NULL;
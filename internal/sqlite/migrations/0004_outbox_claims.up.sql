@@ -0,0 +1,3 @@
+-- The relay claims events for a lease instead of locking them while they are published.
+
+ALTER TABLE outbox_events ADD COLUMN claimed_until TIMESTAMP;
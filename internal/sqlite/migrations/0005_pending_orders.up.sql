@@ -0,0 +1,3 @@
+-- The accrual poller reads orders that are not final yet, oldest first.
+
+CREATE INDEX IF NOT EXISTS idx_orders_pending ON orders (uploaded_at, id) WHERE status IN ('NEW', 'PROCESSING');
@@ -0,0 +1,333 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"strings"
+)
+
+// OrderRepo implements the OrderRepository interface
+type OrderRepo struct {
+	db *sql.DB
+}
+
+// NewOrderRepo creates a new OrderRepo instance
+func NewOrderRepo(db *sql.DB) *OrderRepo {
+	return &OrderRepo{db: db}
+}
+
+// Create adds a new order to the database
+func (r *OrderRepo) Create(ctx context.Context, order *entity.Order) error {
+	query := `
+		INSERT INTO orders (id, user_id, status, uploaded_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING uploaded_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, order.ID, order.UserID, order.Status, now()).Scan(&order.UploadedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("order already exists: %w", repository.ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+
+	return nil
+}
+
+// CreateBatch inserts new orders within one transaction.
+// For orders that already exist it reports the owner instead of inserting them.
+func (r *OrderRepo) CreateBatch(ctx context.Context, userID int64, ids []string) ([]repository.BatchInsertResult, error) {
+	var results []repository.BatchInsertResult
+	err := inTx(ctx, r.db, func(tx querier) error {
+		uploadedAt := now()
+		seen := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			query := `
+				INSERT INTO orders (id, user_id, status, uploaded_at)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (id) DO NOTHING
+			`
+
+			result, err := tx.ExecContext(ctx, query, id, userID, entity.StatusNew, uploadedAt)
+			if err != nil {
+				return fmt.Errorf("failed to create order: %w", err)
+			}
+
+			inserted, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to get affected rows: %w", err)
+			}
+
+			res := repository.BatchInsertResult{ID: id, Inserted: inserted > 0}
+			if !res.Inserted {
+				err := tx.QueryRowContext(ctx, `SELECT user_id FROM orders WHERE id = $1`, id).Scan(&res.OwnerID)
+				if err != nil {
+					return fmt.Errorf("failed to get order owner: %w", err)
+				}
+			}
+			results = append(results, res)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create orders: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetByID retrieves an order by ID
+func (r *OrderRepo) GetByID(ctx context.Context, id string) (*entity.Order, error) {
+	query := `
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE id = $1
+	`
+
+	order := &entity.Order{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&order.ID,
+		&order.UserID,
+		&order.Status,
+		&order.Accrual,
+		&order.UploadedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("order not found: %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get order by id: %w", err)
+	}
+
+	return order, nil
+}
+
+// GetByUserID retrieves all orders for a user
+func (r *OrderRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Order, error) {
+	query := `
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE user_id = $1
+		ORDER BY uploaded_at DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// List retrieves a page of a user's orders using keyset pagination on (uploaded_at, id)
+func (r *OrderRepo) List(ctx context.Context, filter repository.OrderFilter) ([]entity.Order, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if len(filter.Statuses) > 0 {
+		placeholders := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			args = append(args, status)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf("status IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From.UTC())
+		conditions = append(conditions, fmt.Sprintf("uploaded_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To.UTC())
+		conditions = append(conditions, fmt.Sprintf("uploaded_at < $%d", len(args)))
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		args = append(args, filter.After.Time.UTC(), filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(uploaded_at, id) %s ($%d, $%d)", comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE %s
+		ORDER BY uploaded_at %s, id %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), direction, direction, len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetByStatuses retrieves up to limit orders of any user with one of the statuses, oldest first
+func (r *OrderRepo) GetByStatuses(ctx context.Context, statuses []string, limit int) ([]entity.Order, error) {
+	condition, args := statusCondition(statuses)
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, status, accrual, uploaded_at
+		FROM orders
+		WHERE %s
+		ORDER BY uploaded_at, id
+		LIMIT $%d
+	`, condition, len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []entity.Order
+	for rows.Next() {
+		var order entity.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// CountByStatuses counts the orders of all users with one of the statuses
+func (r *OrderRepo) CountByStatuses(ctx context.Context, statuses []string) (int, error) {
+	condition, args := statusCondition(statuses)
+	query := `SELECT COUNT(*) FROM orders WHERE ` + condition
+
+	var count int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	return count, nil
+}
+
+// statusCondition builds a status IN condition with one placeholder per status
+func statusCondition(statuses []string) (string, []interface{}) {
+	if len(statuses) == 0 {
+		return "FALSE", nil
+	}
+
+	args := make([]interface{}, len(statuses))
+	placeholders := make([]string, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	return fmt.Sprintf("status IN (%s)", strings.Join(placeholders, ", ")), args
+}
+
+// UpdateStatus sets the status and accrual of an order that still has the status from
+// and reports whether it did, so concurrent updates of the same order apply only once
+func (r *OrderRepo) UpdateStatus(ctx context.Context, order *entity.Order, from string) (bool, error) {
+	query := `
+		UPDATE orders
+		SET status = $1, accrual = $2
+		WHERE id = $3 AND status = $4
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, order.Status, order.Accrual, order.ID, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// CheckExists checks if an order exists and returns the user ID if it does
+func (r *OrderRepo) CheckExists(ctx context.Context, id string) (bool, int64, error) {
+	query := `
+		SELECT user_id FROM orders WHERE id = $1
+	`
+
+	var userID int64
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, 0, nil
+		}
+		return false, 0, fmt.Errorf("failed to check if order exists: %w", err)
+	}
+
+	return true, userID, nil
+}
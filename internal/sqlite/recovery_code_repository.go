@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gophermart/domain/repository"
+)
+
+// RecoveryCodeRepo implements the RecoveryCodeRepository interface
+type RecoveryCodeRepo struct {
+	db *sql.DB
+}
+
+// NewRecoveryCodeRepo creates a new RecoveryCodeRepo instance
+func NewRecoveryCodeRepo(db *sql.DB) *RecoveryCodeRepo {
+	return &RecoveryCodeRepo{db: db}
+}
+
+// Replace deletes all recovery codes of a user and stores the given ones
+func (r *RecoveryCodeRepo) Replace(ctx context.Context, userID int64, codeHashes []string) error {
+	return inTx(ctx, r.db, func(tx querier) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+
+		for _, hash := range codeHashes {
+			_, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+			if err != nil {
+				return fmt.Errorf("failed to insert recovery code: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Consume atomically marks an unused recovery code as used
+func (r *RecoveryCodeRepo) Consume(ctx context.Context, userID int64, codeHash string) error {
+	query := `
+		UPDATE recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+		RETURNING id
+	`
+
+	var id int64
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID, codeHash, now()).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("recovery code not found: %w", repository.ErrNotFound)
+		}
+		return fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	return nil
+}
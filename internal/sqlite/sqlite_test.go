@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"context"
+	"gophermart/domain/repository/repotest"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(tb testing.TB) repotest.Repositories {
+		tb.Helper()
+
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+		db, err := NewDB(filepath.Join(tb.TempDir(), "gophermart.db"), nil, logger)
+		if err != nil {
+			tb.Fatalf("failed to open database: %v", err)
+		}
+		tb.Cleanup(func() { db.Close() })
+
+		migrator, err := NewMigrator(db, logger)
+		if err != nil {
+			tb.Fatalf("failed to create migrator: %v", err)
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			tb.Fatalf("failed to migrate database: %v", err)
+		}
+
+		return repotest.Repositories{
+			Users:       NewUserRepo(db),
+			Orders:      NewOrderRepo(db),
+			Balances:    NewBalanceRepo(db),
+			Withdrawals: NewWithdrawalRepo(db),
+			Outbox:      NewOutboxRepo(db),
+			Transactor:  NewTransactor(db),
+		}
+	})
+}
+
+func TestNewDBMergesParams(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	params := url.Values{"_pragma": {"cache_size(-4000)"}, "_txlock": {"deferred"}}
+	db, err := NewDB(filepath.Join(t.TempDir(), "gophermart.db"), params, logger)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var cacheSize, foreignKeys int
+	if err := db.QueryRow("PRAGMA cache_size").Scan(&cacheSize); err != nil {
+		t.Fatalf("failed to read cache_size: %v", err)
+	}
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatalf("failed to read foreign_keys: %v", err)
+	}
+	if cacheSize != -4000 || foreignKeys != 1 {
+		t.Errorf("cache_size = %d, foreign_keys = %d, want -4000 and 1", cacheSize, foreignKeys)
+	}
+
+	if got := dsn("gophermart.db", params); strings.Count(got, "?") != 1 || !strings.Contains(got, "_txlock=immediate") || strings.Contains(got, "deferred") {
+		t.Errorf("dsn = %q, want one query with the required _txlock", got)
+	}
+}
@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gophermart/domain/entity"
+	"gophermart/domain/repository"
+	"strings"
+)
+
+// WithdrawalRepo implements the WithdrawalRepository interface
+type WithdrawalRepo struct {
+	db *sql.DB
+}
+
+// NewWithdrawalRepo creates a new WithdrawalRepo instance
+func NewWithdrawalRepo(db *sql.DB) *WithdrawalRepo {
+	return &WithdrawalRepo{db: db}
+}
+
+// Create adds a new withdrawal record
+func (r *WithdrawalRepo) Create(ctx context.Context, withdrawal *entity.Withdrawal) error {
+	query := `
+		INSERT INTO withdrawals (user_id, order_id, sum, processed_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, processed_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, withdrawal.UserID, withdrawal.OrderID, withdrawal.Sum, now()).Scan(
+		&withdrawal.ID,
+		&withdrawal.ProcessedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create withdrawal: %w", err)
+	}
+
+	return nil
+}
+
+// GetByUserID retrieves all withdrawals for a user
+func (r *WithdrawalRepo) GetByUserID(ctx context.Context, userID int64) ([]entity.Withdrawal, error) {
+	query := `
+		SELECT id, user_id, order_id, sum, processed_at
+		FROM withdrawals
+		WHERE user_id = $1
+		ORDER BY processed_at DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query withdrawals: %w", err)
+	}
+	defer rows.Close()
+
+	var withdrawals []entity.Withdrawal
+	for rows.Next() {
+		var w entity.Withdrawal
+		err := rows.Scan(
+			&w.ID,
+			&w.UserID,
+			&w.OrderID,
+			&w.Sum,
+			&w.ProcessedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan withdrawal row: %w", err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating withdrawal rows: %w", err)
+	}
+
+	return withdrawals, nil
+}
+
+// List retrieves a page of a user's withdrawals using keyset pagination on (processed_at, id)
+func (r *WithdrawalRepo) List(ctx context.Context, filter repository.WithdrawalFilter) ([]entity.Withdrawal, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if !filter.From.IsZero() {
+		args = append(args, filter.From.UTC())
+		conditions = append(conditions, fmt.Sprintf("processed_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To.UTC())
+		conditions = append(conditions, fmt.Sprintf("processed_at < $%d", len(args)))
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		args = append(args, filter.After.Time.UTC(), filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(processed_at, id) %s ($%d, $%d)", comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, order_id, sum, processed_at
+		FROM withdrawals
+		WHERE %s
+		ORDER BY processed_at %s, id %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), direction, direction, len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query withdrawals: %w", err)
+	}
+	defer rows.Close()
+
+	var withdrawals []entity.Withdrawal
+	for rows.Next() {
+		var w entity.Withdrawal
+		err := rows.Scan(
+			&w.ID,
+			&w.UserID,
+			&w.OrderID,
+			&w.Sum,
+			&w.ProcessedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan withdrawal row: %w", err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating withdrawal rows: %w", err)
+	}
+
+	return withdrawals, nil
+}